@@ -12,9 +12,10 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/FollG/kafka-with-go/internal/adapters/kafka"
+	"github.com/FollG/kafka-with-go/internal/adapters/memory"
 	"github.com/FollG/kafka-with-go/internal/adapters/postgres"
 	"github.com/FollG/kafka-with-go/internal/adapters/redis"
+	"github.com/FollG/kafka-with-go/internal/domain/repositories"
 	"github.com/FollG/kafka-with-go/internal/domain/services"
 	"github.com/FollG/kafka-with-go/internal/handlers/http/v1"
 	"github.com/FollG/kafka-with-go/internal/pkg/cache"
@@ -56,32 +57,52 @@ func main() {
 	if err != nil {
 		logger.Fatal(context.Background(), "failed to connect to redis", "error", err)
 	}
-	defer func(redisClient *redis2.Client) {
+	defer func(redisClient redis2.UniversalClient) {
 		err := redisClient.Close()
 		if err != nil {
 			logger.Error(context.Background(), "failed to close redis client", "error", err)
 		}
 	}(redisClient)
 
-	// kafka producer
-	producer := kafka.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.Topic)
-	defer func(producer *kafka.Producer) {
-		err := producer.Close()
-		if err != nil {
-			logger.Error(context.Background(), "failed to close producer", "error", err)
-		}
-	}(producer)
-
 	// reps and services
 	productRepo := postgres.NewProductRepository(db)
+	outboxRepo := postgres.NewOutboxRepository(db)
+	operationRepo := postgres.NewOperationRepository(db)
 	productCache := redis.NewProductCache(redisClient, cfg.Redis.TTL)
+	productCache.SetStaleTTL(cfg.Redis.StaleTTL)
+	productCache.SetNegativeTTL(cfg.Redis.NegativeTTL)
+	cacheCodec, err := redis.NewValueCodec(cfg.Redis.Codec, cfg.Redis.Compression)
+	if err != nil {
+		logger.Fatal(context.Background(), "invalid redis codec", "error", err)
+	}
+	productCache.SetCodec(cacheCodec)
 	validator := services.NewProductValidator()
 
+	// фоновые задачи останавливаются вместе с сервером
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// локальный кеш карточек перед Redis, сбрасывается по рассылке процессора
+	var cardCache repositories.ProductCache = productCache
+	if cfg.LocalCache.Size > 0 {
+		localCache := memory.NewProductCache(productCache, cfg.LocalCache.Size, cfg.LocalCache.TTL)
+		cardCache = localCache
+
+		invalidations := redis.NewInvalidations(redisClient, cfg.Redis.InvalidationChannel)
+		go invalidations.Subscribe(bgCtx, localCache.Invalidate)
+	}
+
 	// usecases
-	productUC := usecases.NewProductUseCase(productRepo, productCache, producer, (*vld.ProductValidator)(validator))
+	productUC := usecases.NewProductUseCase(productRepo, cardCache, outboxRepo, (*vld.ProductValidator)(validator))
+	productReads := redis.NewReadCounter(redisClient)
+	go productReads.Run(bgCtx, 5*time.Second)
+	productUC.SetReadTracker(productReads)
+	operationUC := usecases.NewOperationUseCase(operationRepo)
+	importUC := usecases.NewImportUseCase(productRepo, usecases.NewOutboxPublisher(outboxRepo), (*vld.ProductValidator)(validator), cfg.Import.BatchSize)
+	cacheWarmUC := usecases.NewCacheWarmUseCase(productRepo, cardCache, productReads, cfg.CacheWarm.BatchSize, cfg.CacheWarm.Rate)
 
 	// http server
-	router := v1.NewRouter(productUC, db, redisClient, cfg.Server.RateLimit)
+	router := v1.NewRouter(productUC, operationUC, importUC, cacheWarmUC, db, redisClient, cfg.Server.RateLimit, cfg.Server.AdminToken)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
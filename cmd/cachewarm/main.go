@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/FollG/kafka-with-go/internal/adapters/postgres"
+	"github.com/FollG/kafka-with-go/internal/adapters/redis"
+	"github.com/FollG/kafka-with-go/internal/pkg/cache"
+	"github.com/FollG/kafka-with-go/internal/pkg/config"
+	"github.com/FollG/kafka-with-go/internal/pkg/database"
+	"github.com/FollG/kafka-with-go/internal/pkg/logger"
+	"github.com/FollG/kafka-with-go/internal/usecases"
+	redis2 "github.com/redis/go-redis/v9"
+)
+
+func main() {
+	// flags
+	mode := flag.String("mode", string(usecases.WarmAll), "all, recent or most_read")
+	limit := flag.Int("limit", 0, "number of products for recent and most_read")
+	batchSize := flag.Int("batch", 0, "products per Redis pipeline (default: CACHE_WARM_BATCH_SIZE)")
+	rate := flag.Int("rate", -1, "products per second, 0 for no limit (default: CACHE_WARM_RATE)")
+	flag.Parse()
+
+	// conf
+	cfg := config.Load()
+	if *batchSize <= 0 {
+		*batchSize = cfg.CacheWarm.BatchSize
+	}
+	if *rate < 0 {
+		*rate = cfg.CacheWarm.Rate
+	}
+
+	// logger
+	if err := logger.Init(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// psql
+	db, err := database.NewPostgres(cfg.Database)
+	if err != nil {
+		logger.Fatal(ctx, "failed to connect to database", "error", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	// redis
+	redisClient, err := cache.NewRedisClient(cfg.Redis)
+	if err != nil {
+		logger.Fatal(ctx, "failed to connect to redis", "error", err)
+	}
+	defer func(redisClient redis2.UniversalClient) {
+		_ = redisClient.Close()
+	}(redisClient)
+
+	productRepo := postgres.NewProductRepository(db)
+	productCache := redis.NewProductCache(redisClient, cfg.Redis.TTL)
+	productCache.SetStaleTTL(cfg.Redis.StaleTTL)
+	productCache.SetNegativeTTL(cfg.Redis.NegativeTTL)
+	cacheCodec, err := redis.NewValueCodec(cfg.Redis.Codec, cfg.Redis.Compression)
+	if err != nil {
+		logger.Fatal(ctx, "invalid redis codec", "error", err)
+	}
+	productCache.SetCodec(cacheCodec)
+
+	warmUC := usecases.NewCacheWarmUseCase(productRepo, productCache, redis.NewReadCounter(redisClient), *batchSize, *rate)
+
+	report, err := warmUC.Warm(ctx, usecases.WarmOptions{
+		Mode:  usecases.WarmMode(*mode),
+		Limit: *limit,
+	})
+	if err != nil {
+		logger.Fatal(ctx, "cache warm-up failed",
+			"mode", *mode,
+			"written", report.Written,
+			"error", err,
+		)
+	}
+
+	logger.Info(ctx, "cache warm-up finished",
+		"mode", *mode,
+		"written", report.Written,
+		"batches", report.Batches,
+		"duration", report.FinishedAt.Sub(report.StartedAt).String(),
+	)
+}
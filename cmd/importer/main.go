@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/FollG/kafka-with-go/internal/adapters/postgres"
+	"github.com/FollG/kafka-with-go/internal/pkg/config"
+	"github.com/FollG/kafka-with-go/internal/pkg/database"
+	"github.com/FollG/kafka-with-go/internal/pkg/importer"
+	"github.com/FollG/kafka-with-go/internal/pkg/logger"
+	vld "github.com/FollG/kafka-with-go/internal/pkg/validator"
+	"github.com/FollG/kafka-with-go/internal/usecases"
+)
+
+func main() {
+	// flags
+	file := flag.String("file", "", "path to a CSV or NDJSON catalog file, - for stdin")
+	format := flag.String("format", "", "csv or ndjson; by default taken from the file extension")
+	rejectsPath := flag.String("rejects", "", "path to the rejects file (default: <file>.rejects.csv)")
+	batchSize := flag.Int("batch", 0, "events per outbox write (default: IMPORT_BATCH_SIZE)")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// conf
+	cfg := config.Load()
+	if *batchSize <= 0 {
+		*batchSize = cfg.Import.BatchSize
+	}
+
+	// logger
+	if err := logger.Init(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// input
+	importFormat := importer.Format(*format)
+	if importFormat == "" {
+		detected, ok := importer.FormatFromPath(*file)
+		if !ok {
+			logger.Fatal(ctx, "cannot detect file format, use -format", "file", *file)
+		}
+		importFormat = detected
+	}
+
+	var input io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			logger.Fatal(ctx, "failed to open file", "file", *file, "error", err)
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		input = f
+	}
+
+	reader, err := importer.NewReader(input, importFormat)
+	if err != nil {
+		logger.Fatal(ctx, "failed to read file", "file", *file, "error", err)
+	}
+
+	// rejects
+	if *rejectsPath == "" {
+		*rejectsPath = "rejects.csv"
+		if *file != "-" {
+			*rejectsPath = *file + ".rejects.csv"
+		}
+	}
+	rejectsFile, err := os.Create(*rejectsPath)
+	if err != nil {
+		logger.Fatal(ctx, "failed to create rejects file", "file", *rejectsPath, "error", err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(rejectsFile)
+
+	rejects := csv.NewWriter(rejectsFile)
+	if err := rejects.Write([]string{"line", "reason"}); err != nil {
+		logger.Fatal(ctx, "failed to write rejects file", "error", err)
+	}
+
+	// psql
+	db, err := database.NewPostgres(cfg.Database)
+	if err != nil {
+		logger.Fatal(ctx, "failed to connect to database", "error", err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logger.Error(context.Background(), "failed to close database connection", "error", err)
+		}
+	}(db)
+
+	// события идут через outbox, как и у POST /imports: в Kafka их отправит relay
+	importUC := usecases.NewImportUseCase(
+		postgres.NewProductRepository(db),
+		usecases.NewOutboxPublisher(postgres.NewOutboxRepository(db)),
+		vld.NewProductValidator(),
+		*batchSize,
+	)
+
+	logger.Info(ctx, "starting import", "file", *file, "format", importFormat)
+
+	report, err := importUC.Import(ctx, reader, func(reject usecases.ImportReject) error {
+		return rejects.Write([]string{strconv.Itoa(reject.Line), reject.Reason})
+	})
+	rejects.Flush()
+	if flushErr := rejects.Error(); flushErr != nil {
+		logger.Error(ctx, "failed to write rejects file", "file", *rejectsPath, "error", flushErr)
+	}
+
+	fields := []interface{}{
+		"rows", report.Rows,
+		"imported", report.Imported,
+		"rejected", report.Rejected,
+		"rejects_file", *rejectsPath,
+	}
+	if err != nil {
+		logger.Fatal(ctx, "import failed", append(fields, "error", err)...)
+	}
+
+	logger.Info(ctx, "import finished", fields...)
+}
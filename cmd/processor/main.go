@@ -17,6 +17,8 @@ import (
 	"github.com/FollG/kafka-with-go/internal/pkg/database"
 	"github.com/FollG/kafka-with-go/internal/pkg/logger"
 	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
+	vld "github.com/FollG/kafka-with-go/internal/pkg/validator"
+	"github.com/FollG/kafka-with-go/internal/usecases"
 	redis2 "github.com/redis/go-redis/v9"
 )
 
@@ -49,7 +51,7 @@ func main() {
 	if err != nil {
 		logger.Fatal(context.Background(), "failed to connect to redis", "error", err)
 	}
-	defer func(redisClient *redis2.Client) {
+	defer func(redisClient redis2.UniversalClient) {
 		err := redisClient.Close()
 		if err != nil {
 			panic(err)
@@ -58,18 +60,51 @@ func main() {
 
 	// repo init
 	productRepo := postgres.NewProductRepository(db)
+	operationRepo := postgres.NewOperationRepository(db)
 	productCache := redis.NewProductCache(redisClient, cfg.Redis.TTL)
+	productCache.SetStaleTTL(cfg.Redis.StaleTTL)
+	productCache.SetNegativeTTL(cfg.Redis.NegativeTTL)
+	cacheCodec, err := redis.NewValueCodec(cfg.Redis.Codec, cfg.Redis.Compression)
+	if err != nil {
+		logger.Fatal(context.Background(), "invalid redis codec", "error", err)
+	}
+	productCache.SetCodec(cacheCodec)
 
 	// kafka consumer
 	consumer := kafka.NewConsumer(
 		cfg.Kafka.Brokers,
 		cfg.Kafka.Topic,
 		cfg.Kafka.ConsumerGroup,
-		100,                  // batchSize
-		100*time.Millisecond, // batchTimeout
+		cfg.Kafka.BatchSize,
+		cfg.Kafka.BatchTimeout,
 	)
 	consumer.SetProductRepo(productRepo)
 	consumer.SetCache(productCache)
+	consumer.SetInvalidator(redis.NewInvalidations(redisClient, cfg.Redis.InvalidationChannel))
+	consumer.SetOperationRepo(operationRepo)
+	consumer.SetProductValidation(vld.NewProductValidator().Validate)
+	consumer.SetWorkers(cfg.Kafka.Workers)
+	consumer.SetBatchMode(cfg.Kafka.BatchMode)
+
+	// retry topics & dlq
+	retryPolicy, err := kafka.NewRetryPolicy(cfg.Kafka.RetryTopics, cfg.Kafka.RetryDelays, cfg.Kafka.DLQTopic)
+	if err != nil {
+		logger.Fatal(context.Background(), "invalid retry policy", "error", err)
+	}
+	consumer.SetRetryPolicy(retryPolicy)
+
+	// processed_events retention
+	var retryWindow time.Duration
+	for _, delay := range cfg.Kafka.RetryDelays {
+		retryWindow += delay
+	}
+	if cfg.Kafka.ProcessedRetention <= retryWindow {
+		logger.Fatal(context.Background(), "processed events retention must exceed retry delays",
+			"retention", cfg.Kafka.ProcessedRetention,
+			"retry_window", retryWindow,
+		)
+	}
+	cleaner := usecases.NewProcessedEventsCleaner(productRepo, cfg.Kafka.ProcessedRetention, cfg.Kafka.ProcessedCleanupInterval)
 	defer func(consumer *kafka.Consumer) {
 		err := consumer.Close()
 		if err != nil {
@@ -90,6 +125,9 @@ func main() {
 			logger.Fatal(ctx, "failed to start consumer", "error", err)
 		}
 	}()
+	go func() {
+		_ = cleaner.Start(ctx)
+	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
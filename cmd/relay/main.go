@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/adapters/kafka"
+	"github.com/FollG/kafka-with-go/internal/adapters/postgres"
+	"github.com/FollG/kafka-with-go/internal/pkg/config"
+	"github.com/FollG/kafka-with-go/internal/pkg/database"
+	"github.com/FollG/kafka-with-go/internal/pkg/logger"
+	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
+	"github.com/FollG/kafka-with-go/internal/usecases"
+)
+
+func main() {
+	// conf
+	cfg := config.Load()
+
+	// logger
+	if err := logger.Init(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	// metrics
+	metrics.Init(cfg.Metrics.Port)
+
+	// psql
+	db, err := database.NewPostgres(cfg.Database)
+	if err != nil {
+		logger.Fatal(context.Background(), "failed to connect to database", "error", err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logger.Error(context.Background(), "failed to close database connection", "error", err)
+		}
+	}(db)
+
+	// kafka producer
+	producer := kafka.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.Topic)
+	defer func(producer *kafka.Producer) {
+		err := producer.Close()
+		if err != nil {
+			logger.Error(context.Background(), "failed to close producer", "error", err)
+		}
+	}(producer)
+
+	// outbox relay
+	outboxRepo := postgres.NewOutboxRepository(db)
+	relay := usecases.NewOutboxRelay(
+		outboxRepo,
+		producer,
+		cfg.Outbox.BatchSize,
+		cfg.Outbox.PollInterval,
+		cfg.Outbox.MinBackoff,
+		cfg.Outbox.MaxBackoff,
+		cfg.Outbox.Retention,
+	)
+	relay.SetLease(cfg.Outbox.Lease)
+
+	// graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Info(ctx, "starting outbox relay", "topic", cfg.Kafka.Topic)
+		if err := relay.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Fatal(ctx, "outbox relay stopped", "error", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info(ctx, "shutting down relay...")
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		logger.Error(context.Background(), "relay did not stop in time")
+	}
+
+	logger.Info(context.Background(), "relay exited")
+}
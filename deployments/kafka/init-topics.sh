@@ -21,6 +21,31 @@ echo "Kafka is ready. Creating topics..."
 
 echo "Topic 'products' created successfully"
 
+for topic in products.retry.1m products.retry.10m; do
+  /opt/kafka/bin/kafka-topics.sh --bootstrap-server kafka1:9092 \
+    --create \
+    --if-not-exists \
+    --topic "$topic" \
+    --partitions 3 \
+    --replication-factor 3 \
+    --config retention.ms=86400000 \
+    --config cleanup.policy=delete
+
+  echo "Topic '$topic' created successfully"
+done
+
+# DLQ хранится неделю, чтобы успеть разобрать и переотправить сообщения
+/opt/kafka/bin/kafka-topics.sh --bootstrap-server kafka1:9092 \
+  --create \
+  --if-not-exists \
+  --topic products.dlq \
+  --partitions 3 \
+  --replication-factor 3 \
+  --config retention.ms=604800000 \
+  --config cleanup.policy=delete
+
+echo "Topic 'products.dlq' created successfully"
+
 echo "Current topics:"
 /opt/kafka/bin/kafka-topics.sh --bootstrap-server kafka1:9092 --list
 
@@ -29,9 +29,20 @@ CREATE TABLE IF NOT EXISTS products (
 CREATE INDEX IF NOT EXISTS idx_products_type ON products(type);
 CREATE INDEX IF NOT EXISTS idx_products_price ON products(price);
 CREATE INDEX IF NOT EXISTS idx_products_color ON products(color);
-CREATE INDEX IF NOT EXISTS idx_products_created_at ON products(created_at);
+CREATE INDEX IF NOT EXISTS idx_products_created_at_id ON products(created_at DESC, id DESC);
 CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING GIN(attributes);
 
+-- Индексы для сортировки (параметр sort)
+CREATE INDEX IF NOT EXISTS idx_products_name ON products(name);
+CREATE INDEX IF NOT EXISTS idx_products_weight ON products(weight);
+CREATE INDEX IF NOT EXISTS idx_products_updated_at ON products(updated_at);
+CREATE INDEX IF NOT EXISTS idx_products_warranty_months ON products(((attributes->>'warranty_months')::int));
+CREATE INDEX IF NOT EXISTS idx_products_foot_size ON products(((attributes->>'foot_size')::numeric));
+CREATE INDEX IF NOT EXISTS idx_products_head_circumference ON products(((attributes->>'head_circumference')::numeric));
+CREATE INDEX IF NOT EXISTS idx_products_chest_circumference ON products(((attributes->>'chest_circumference')::numeric));
+CREATE INDEX IF NOT EXISTS idx_products_waist_circumference ON products(((attributes->>'waist_circumference')::numeric));
+CREATE INDEX IF NOT EXISTS idx_products_hip_circumference ON products(((attributes->>'hip_circumference')::numeric));
+
 -- Триггер для обновления updated_at
 CREATE OR REPLACE FUNCTION update_updated_at_column()
 RETURNS TRIGGER AS $$
@@ -44,4 +55,94 @@ $$ language 'plpgsql';
 CREATE OR REPLACE TRIGGER update_products_updated_at
     BEFORE UPDATE ON products
                          FOR EACH ROW
-                         EXECUTE FUNCTION update_updated_at_column();
\ No newline at end of file
+                         EXECUTE FUNCTION update_updated_at_column();
+
+-- Transactional outbox: события, принятые API и ожидающие публикации в Kafka
+CREATE TABLE IF NOT EXISTS product_outbox (
+                                              id BIGSERIAL PRIMARY KEY,
+                                              event_id VARCHAR(64) NOT NULL UNIQUE,
+    event_type VARCHAR(32) NOT NULL,
+    aggregate_key VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ
+    );
+
+CREATE INDEX IF NOT EXISTS idx_product_outbox_pending ON product_outbox(next_attempt_at, id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_product_outbox_key ON product_outbox(aggregate_key, id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_product_outbox_sent_at ON product_outbox(sent_at) WHERE sent_at IS NOT NULL;
+
+-- Идемпотентность процессора: события, уже примененные к products
+CREATE TABLE IF NOT EXISTS processed_events (
+                                                event_id VARCHAR(64) PRIMARY KEY,
+    event_type VARCHAR(32) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events(processed_at);
+
+-- Статусы асинхронных операций (GET /api/v1/operations/{id})
+CREATE TABLE IF NOT EXISTS operations (
+                                          id VARCHAR(64) PRIMARY KEY,
+    event_type VARCHAR(32) NOT NULL,
+    product_id BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_operations_created_at ON operations(created_at);
+
+-- Версия товара для оптимистичной блокировки (ETag / If-Match)
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+-- Sequence последнего примененного события товара; строка переживает удаление товара
+CREATE TABLE IF NOT EXISTS product_sequences (
+                                                 product_id BIGINT PRIMARY KEY,
+    last_sequence BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+-- Одиночный индекс по created_at заменен индексом (created_at, id) под keyset-пагинацию
+DROP INDEX IF EXISTS idx_products_created_at;
+
+-- Полнотекстовый поиск: вектор по названию, цвету, материалу и пищевой ценности
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION update_products_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector =
+        setweight(to_tsvector('russian', COALESCE(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('russian', COALESCE(NEW.color, '')), 'B') ||
+        setweight(to_tsvector('russian', COALESCE(NEW.attributes->>'material', '')), 'B') ||
+        setweight(to_tsvector('russian', COALESCE(NEW.attributes->>'nutritional_info', '')), 'C');
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE TRIGGER update_products_search_vector
+    BEFORE INSERT OR UPDATE OF name, color, attributes ON products
+                         FOR EACH ROW
+                         EXECUTE FUNCTION update_products_search_vector();
+
+-- Товары, созданные до появления триггера (updated_at при этом не меняется)
+ALTER TABLE products DISABLE TRIGGER update_products_updated_at;
+UPDATE products SET name = name WHERE search_vector IS NULL;
+ALTER TABLE products ENABLE TRIGGER update_products_updated_at;
+
+CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN(search_vector);
+
+-- Sequence событий выдает outbox; последовательность выше прежних значений по часам API
+SELECT setval(
+    pg_get_serial_sequence('product_outbox', 'id'),
+    GREATEST(
+        (SELECT COALESCE(MAX(last_sequence), 0) FROM product_sequences),
+        (EXTRACT(EPOCH FROM NOW() + INTERVAL '1 day') * 1000000000)::BIGINT,
+        (SELECT COALESCE(MAX(id), 1) FROM product_outbox)
+    )
+);
@@ -0,0 +1,115 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// SetBatchMode включает пакетное применение событий основного топика:
+// до batchSize сообщений, собранных за batchTimeout, применяются одной
+// транзакцией через ProductRepository.ApplyBatch
+func (c *Consumer) SetBatchMode(enabled bool) {
+	c.batchMode = enabled
+}
+
+// consumeBatches - цикл основного топика в пакетном режиме. Offset'ы
+// коммитятся только после успешного коммита транзакции.
+func (c *Consumer) consumeBatches(ctx context.Context, reader *kafka.Reader) error {
+	for {
+		batch, err := c.fetchBatch(ctx, reader)
+		if err != nil {
+			return err
+		}
+
+		if err := c.applyBatch(ctx, batch); err != nil {
+			return err
+		}
+
+		if err := reader.CommitMessages(ctx, batch...); err != nil {
+			return fmt.Errorf("failed to commit messages: %w", err)
+		}
+	}
+}
+
+// fetchBatch ждет первое сообщение, затем добирает пачку, пока она не
+// заполнится или не истечет batchTimeout
+func (c *Consumer) fetchBatch(ctx context.Context, reader *kafka.Reader) ([]kafka.Message, error) {
+	first, err := reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch message: %w", err)
+	}
+
+	batch := make([]kafka.Message, 0, max(c.batchSize, 1))
+	batch = append(batch, first)
+
+	fetchCtx, cancel := context.WithTimeout(ctx, c.batchTimeout)
+	defer cancel()
+
+	for len(batch) < c.batchSize {
+		msg, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break
+			}
+			return nil, fmt.Errorf("failed to fetch message: %w", err)
+		}
+		batch = append(batch, msg)
+	}
+
+	return batch, nil
+}
+
+// applyBatch применяет пачку одной транзакцией. Битые сообщения сразу уходят
+// в DLQ, а если транзакция не прошла, пачка разбирается по одному сообщению,
+// чтобы одно плохое событие не блокировало остальные.
+func (c *Consumer) applyBatch(ctx context.Context, batch []kafka.Message) error {
+	events := make([]*models.ProductEvent, 0, len(batch))
+	valid := make([]kafka.Message, 0, len(batch))
+
+	for _, msg := range batch {
+		event, err := decodeEvent(msg)
+		if err != nil {
+			if err := c.handleMessage(ctx, msg, 0); err != nil {
+				return err
+			}
+			continue
+		}
+		events = append(events, event)
+		valid = append(valid, msg)
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	applied, err := c.productRepo.ApplyBatch(ctx, events)
+	if err != nil {
+		fmt.Printf("Failed to apply batch of %d events, falling back to single messages: %v\n", len(events), err)
+		for _, msg := range valid {
+			if err := c.handleMessage(ctx, msg, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	c.refreshCache(ctx, applied...)
+	for _, msg := range valid {
+		metrics.RecordKafkaMessageProcessed(msg.Topic, "success")
+	}
+
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.EventID
+	}
+	c.completeOperations(ctx, models.OperationApplied, "", ids...)
+
+	fmt.Printf("Successfully applied batch: %d messages, %d changes\n", len(valid), len(applied))
+	return nil
+}
@@ -3,11 +3,15 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/FollG/kafka-with-go/internal/domain/models"
 	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl/plain"
@@ -17,8 +21,18 @@ type Consumer struct {
 	reader       *kafka.Reader
 	productRepo  repositories.ProductRepository
 	cache        repositories.ProductCache
+	invalidator  repositories.CacheInvalidator
+	operations   repositories.OperationRepository
+	validate     func(*models.Product) error
 	batchSize    int
 	batchTimeout time.Duration
+
+	workers   int
+	batchMode bool
+
+	retry        *RetryPolicy
+	retryWriter  *kafka.Writer
+	retryReaders []*kafka.Reader
 }
 
 func NewConsumer(brokers []string, topic, groupID string, batchSize int, batchTimeout time.Duration) *Consumer {
@@ -38,6 +52,7 @@ func NewConsumer(brokers []string, topic, groupID string, batchSize int, batchTi
 		reader:       reader,
 		batchSize:    batchSize,
 		batchTimeout: batchTimeout,
+		workers:      1,
 	}
 }
 
@@ -68,6 +83,7 @@ func NewConsumerWithAuth(brokers []string, topic, groupID, username, password st
 		reader:       reader,
 		batchSize:    batchSize,
 		batchTimeout: batchTimeout,
+		workers:      1,
 	}
 }
 
@@ -79,97 +95,360 @@ func (c *Consumer) SetCache(cache repositories.ProductCache) {
 	c.cache = cache
 }
 
+// SetInvalidator включает рассылку измененных ключей локальным кешам реплик API
+func (c *Consumer) SetInvalidator(invalidator repositories.CacheInvalidator) {
+	c.invalidator = invalidator
+}
+
+// SetProductValidation задает проверку товара, получившегося после
+// product_patched; непрошедший проверку патч не применяется
+func (c *Consumer) SetProductValidation(validate func(*models.Product) error) {
+	c.validate = validate
+}
+
+// SetOperationRepo включает обновление статусов операций (GET /api/v1/operations/{id})
+func (c *Consumer) SetOperationRepo(repo repositories.OperationRepository) {
+	c.operations = repo
+}
+
+// SetWorkers задает число параллельных воркеров. События одного товара
+// (один ключ сообщения) всегда обрабатывает один и тот же воркер.
+func (c *Consumer) SetWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	c.workers = workers
+}
+
+// SetRetryPolicy включает retry-топики и DLQ. Для каждого retry-топика
+// заводится свой reader с группой <groupID>.<topic>.
+func (c *Consumer) SetRetryPolicy(policy RetryPolicy) {
+	cfg := c.reader.Config()
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Balancer:     &kafka.Hash{}, // ключ product-<id> сохраняет партицию
+		RequiredAcks: kafka.RequireAll,
+		MaxAttempts:  3,
+	}
+	if cfg.Dialer != nil && cfg.Dialer.SASLMechanism != nil {
+		writer.Transport = &kafka.Transport{SASL: cfg.Dialer.SASLMechanism}
+	}
+
+	readers := make([]*kafka.Reader, len(policy.Tiers))
+	for i, tier := range policy.Tiers {
+		tierCfg := cfg
+		tierCfg.Topic = tier.Topic
+		tierCfg.GroupID = fmt.Sprintf("%s.%s", cfg.GroupID, tier.Topic)
+		tierCfg.StartOffset = kafka.FirstOffset
+		readers[i] = kafka.NewReader(tierCfg)
+	}
+
+	c.retry = &policy
+	c.retryWriter = writer
+	c.retryReaders = readers
+}
+
 func (c *Consumer) Start(ctx context.Context) error {
-	for {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errCh := make(chan error, 1+len(c.retryReaders))
+	go func() {
+		if c.batchMode {
+			errCh <- c.consumeBatches(ctx, c.reader)
+			return
+		}
+		errCh <- c.consume(ctx, c.reader, 0)
+	}()
+	for i, reader := range c.retryReaders {
+		go func(reader *kafka.Reader, tier int) {
+			errCh <- c.consume(ctx, reader, tier)
+		}(reader, i+1)
+	}
+
+	// Любой остановившийся reader останавливает остальные
+	return <-errCh
+}
+
+// consume читает один топик и раздает сообщения воркерам по ключу.
+// tier - уровень повтора: 0 для основного топика, i для i-го retry-топика.
+func (c *Consumer) consume(ctx context.Context, reader *kafka.Reader, tier int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pool := newWorkerPool(c.workers, c.batchSize)
+	tracker := newOffsetTracker()
+
+	errCh := make(chan error, 1)
+	fail := func(err error) {
 		select {
-		case <-ctx.Done():
-			return ctx.Err()
+		case errCh <- err:
 		default:
-			msg, err := c.reader.FetchMessage(ctx)
-			if err != nil {
-				return fmt.Errorf("failed to fetch message: %w", err)
+		}
+		cancel()
+	}
+
+	var commitMu sync.Mutex
+	pool.start(func(msg kafka.Message) {
+		if ctx.Err() != nil {
+			return // не коммитим: сообщение придет повторно после перезапуска
+		}
+
+		if err := c.handleMessage(ctx, msg, tier); err != nil {
+			fail(err)
+			return
+		}
+
+		// Коммиты сериализуются, чтобы offset партиции не откатился назад
+		commitMu.Lock()
+		defer commitMu.Unlock()
+		if commit, ok := tracker.markDone(msg); ok {
+			if err := reader.CommitMessages(ctx, commit); err != nil {
+				fail(fmt.Errorf("failed to commit message: %w", err))
 			}
+		}
+	})
+	defer pool.stop()
 
-			if err := c.processMessage(ctx, msg); err != nil {
-				fmt.Printf("Failed to process message: %v\n", err)
-				continue
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			select {
+			case poolErr := <-errCh:
+				return poolErr
+			default:
 			}
+			return fmt.Errorf("failed to fetch message: %w", err)
+		}
 
-			if err := c.reader.CommitMessages(ctx, msg); err != nil {
-				return fmt.Errorf("failed to commit message: %w", err)
+		if tier > 0 {
+			if err := waitForRetry(ctx, msg, c.retry.Tiers[tier-1].Delay); err != nil {
+				return err
 			}
 		}
+
+		tracker.track(msg)
+		if err := pool.dispatch(ctx, msg); err != nil {
+			return err
+		}
 	}
 }
 
+// handleMessage обрабатывает одно сообщение. Ошибка возвращается только если
+// сообщение нельзя ни применить, ни переложить в retry/DLQ.
+func (c *Consumer) handleMessage(ctx context.Context, msg kafka.Message, tier int) error {
+	err := c.processMessage(ctx, msg)
+	if err == nil {
+		metrics.RecordKafkaMessageProcessed(msg.Topic, "success")
+		c.completeOperations(ctx, models.OperationApplied, "", eventIDOf(msg))
+		return nil
+	}
+
+	// Устаревший If-Match, событие старше уже примененного или патч, после
+	// которого товар не проходит проверку, - итог операции, а не сбой:
+	// повтор его не исправит
+	switch {
+	case errors.Is(err, models.ErrVersionConflict):
+		c.skipMessage(ctx, msg, models.OperationConflict, err)
+		return nil
+	case errors.Is(err, models.ErrStaleEvent):
+		c.skipMessage(ctx, msg, models.OperationStale, err)
+		return nil
+	case errors.Is(err, models.ErrInvalidPatch), errors.Is(err, models.ErrInvalidProduct):
+		c.skipMessage(ctx, msg, models.OperationFailed, err)
+		return nil
+	}
+
+	if c.retry == nil {
+		fmt.Printf("Failed to process message: %v\n", err)
+		metrics.RecordKafkaMessageProcessed(msg.Topic, "failed")
+		if id := eventIDOf(msg); id != "" {
+			c.completeOperations(ctx, models.OperationFailed, err.Error(), id)
+		}
+		return nil
+	}
+
+	return c.handleFailure(ctx, msg, tier, err)
+}
+
 func (c *Consumer) processMessage(ctx context.Context, msg kafka.Message) error {
-	var event models.ProductEvent
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal event: %w", err)
+	event, err := decodeEvent(msg)
+	if err != nil {
+		return err
 	}
 
 	switch event.EventType {
 	case models.ProductCreated:
-		return c.handleProductCreated(ctx, &event)
+		return c.handleProductCreated(ctx, event)
 	case models.ProductUpdated:
-		return c.handleProductUpdated(ctx, &event)
-	case models.ProductDeleted:
-		return c.handleProductDeleted(ctx, &event)
+		return c.handleProductUpdated(ctx, event)
+	case models.ProductPatched:
+		return c.handleProductPatched(ctx, event)
 	default:
-		return fmt.Errorf("unknown event type: %s", event.EventType)
+		return c.handleProductDeleted(ctx, event)
 	}
 }
 
-func (c *Consumer) handleProductCreated(ctx context.Context, event *models.ProductEvent) error {
-	if event.ProductData == nil {
-		return fmt.Errorf("product data is nil for create event")
+// decodeEvent разбирает сообщение; все ошибки здесь постоянные
+func decodeEvent(msg kafka.Message) (*models.ProductEvent, error) {
+	var event models.ProductEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, permanent(fmt.Errorf("failed to unmarshal event: %w", err))
 	}
 
-	if err := c.productRepo.Create(ctx, event.ProductData); err != nil {
-		return fmt.Errorf("failed to create product: %w", err)
+	switch event.EventType {
+	case models.ProductCreated, models.ProductUpdated:
+		if event.ProductData == nil {
+			return nil, permanent(fmt.Errorf("product data is nil for %s event", event.EventType))
+		}
+	case models.ProductPatched:
+		if event.ProductID == 0 || len(event.Patch) == 0 {
+			return nil, permanent(fmt.Errorf("product id or patch is missing for %s event", event.EventType))
+		}
+	case models.ProductDeleted:
+	default:
+		return nil, permanent(fmt.Errorf("unknown event type: %s", event.EventType))
 	}
 
-	cacheKey := fmt.Sprintf("product:%d", event.ProductData.ID)
-	if err := c.cache.Set(ctx, cacheKey, event.ProductData); err != nil {
-		fmt.Printf("Failed to cache product %d: %v\n", event.ProductData.ID, err)
+	return &event, nil
+}
+
+func (c *Consumer) handleProductCreated(ctx context.Context, event *models.ProductEvent) error {
+	if applied, err := c.applyEvent(ctx, event); err != nil || !applied {
+		return err
 	}
 
+	c.refreshCache(ctx, event)
+
 	fmt.Printf("Successfully created product: %d\n", event.ProductData.ID)
 	return nil
 }
 
 func (c *Consumer) handleProductUpdated(ctx context.Context, event *models.ProductEvent) error {
-	if event.ProductData == nil {
-		return fmt.Errorf("product data is nil for update event")
+	if applied, err := c.applyEvent(ctx, event); err != nil || !applied {
+		return err
 	}
 
-	if err := c.productRepo.Update(ctx, event.ProductData); err != nil {
-		return fmt.Errorf("failed to update product: %w", err)
-	}
+	c.refreshCache(ctx, event)
 
-	cacheKey := fmt.Sprintf("product:%d", event.ProductData.ID)
-	if err := c.cache.Set(ctx, cacheKey, event.ProductData); err != nil {
-		fmt.Printf("Failed to cache product %d: %v\n", event.ProductData.ID, err)
+	fmt.Printf("Successfully updated product: %d\n", event.ProductData.ID)
+	return nil
+}
+
+func (c *Consumer) handleProductPatched(ctx context.Context, event *models.ProductEvent) error {
+	if applied, err := c.applyEvent(ctx, event); err != nil || !applied {
+		return err
 	}
 
-	fmt.Printf("Successfully updated product: %d\n", event.ProductData.ID)
+	c.refreshCache(ctx, event)
+
+	fmt.Printf("Successfully patched product: %d\n", event.ProductID)
 	return nil
 }
 
 func (c *Consumer) handleProductDeleted(ctx context.Context, event *models.ProductEvent) error {
-	if err := c.productRepo.Delete(ctx, event.ProductID); err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
+	if applied, err := c.applyEvent(ctx, event); err != nil || !applied {
+		return err
 	}
 
-	cacheKey := fmt.Sprintf("product:%d", event.ProductID)
-	if err := c.cache.Delete(ctx, cacheKey); err != nil {
-		fmt.Printf("Failed to delete product %d from cache: %v\n", event.ProductID, err)
-	}
+	c.refreshCache(ctx, event)
 
 	fmt.Printf("Successfully deleted product: %d\n", event.ProductID)
 	return nil
 }
 
+// refreshCache приводит кеш в соответствие с примененными событиями:
+// обновляет карточки товаров, рассылает их ключи локальным кешам реплик API
+// и один раз сбрасывает кеш списков
+func (c *Consumer) refreshCache(ctx context.Context, events ...*models.ProductEvent) {
+	keys := make([]string, 0, len(events))
+	for _, event := range events {
+		if event.EventType == models.ProductDeleted {
+			cacheKey := fmt.Sprintf("product:%d", event.ProductID)
+			keys = append(keys, cacheKey)
+			// ProductData удаленного товара заполняет репозиторий: в ней версия удаленной строки
+			version := int64(math.MaxInt64)
+			if event.ProductData != nil {
+				version = event.ProductData.Version
+			}
+			if err := c.cache.SetDeleted(ctx, cacheKey, version); err != nil {
+				fmt.Printf("Failed to delete product %d from cache: %v\n", event.ProductID, err)
+			}
+			continue
+		}
+
+		cacheKey := fmt.Sprintf("product:%d", event.ProductData.ID)
+		keys = append(keys, cacheKey)
+		if err := c.cache.Set(ctx, cacheKey, event.ProductData); err != nil {
+			fmt.Printf("Failed to cache product %d: %v\n", event.ProductData.ID, err)
+		}
+	}
+
+	// Рассылка после записи в Redis: реплика, получившая ключ, прочитает уже новое значение
+	if c.invalidator != nil {
+		if err := c.invalidator.PublishInvalidation(ctx, keys...); err != nil {
+			fmt.Printf("Failed to publish cache invalidation: %v\n", err)
+		}
+	}
+
+	if len(events) > 0 {
+		if err := c.cache.InvalidateLists(ctx); err != nil {
+			fmt.Printf("Failed to invalidate cached lists: %v\n", err)
+		}
+	}
+}
+
+// applyEvent применяет событие в БД; false без ошибки означает,
+// что событие уже было применено раньше (повторная доставка)
+func (c *Consumer) applyEvent(ctx context.Context, event *models.ProductEvent) (bool, error) {
+	err := c.productRepo.ApplyEvent(ctx, event, c.validate)
+	if errors.Is(err, models.ErrEventAlreadyProcessed) {
+		fmt.Printf("Skipping already processed event %s (%s)\n", event.EventID, event.EventType)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to apply %s event %s: %w", event.EventType, event.EventID, err)
+	}
+	return true, nil
+}
+
+// skipMessage завершает сообщение без применения события
+func (c *Consumer) skipMessage(ctx context.Context, msg kafka.Message, status models.OperationStatus, reason error) {
+	fmt.Printf("Skipping message %s/%d/%d: %v\n", msg.Topic, msg.Partition, msg.Offset, reason)
+	metrics.RecordKafkaMessageProcessed(msg.Topic, string(status))
+	c.completeOperations(ctx, status, reason.Error(), eventIDOf(msg))
+}
+
+// completeOperations сохраняет итог обработки; ошибка не мешает обработке сообщений
+func (c *Consumer) completeOperations(ctx context.Context, status models.OperationStatus, reason string, ids ...string) {
+	if c.operations == nil {
+		return
+	}
+	if err := c.operations.UpdateStatus(ctx, status, reason, ids...); err != nil {
+		fmt.Printf("Failed to mark operations %v as %s: %v\n", ids, status, err)
+	}
+}
+
+// eventIDOf достает EventID из сообщения, которое может не разбираться целиком
+func eventIDOf(msg kafka.Message) string {
+	var event struct {
+		EventID string `json:"event_id"`
+	}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return ""
+	}
+	return event.EventID
+}
+
 func (c *Consumer) Close() error {
-	return c.reader.Close()
+	var errs []error
+	errs = append(errs, c.reader.Close())
+	for _, reader := range c.retryReaders {
+		errs = append(errs, reader.Close())
+	}
+	if c.retryWriter != nil {
+		errs = append(errs, c.retryWriter.Close())
+	}
+	return errors.Join(errs...)
 }
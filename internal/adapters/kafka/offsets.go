@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker отслеживает сообщения, которые обрабатываются параллельно.
+// Offset партиции можно закоммитить только когда обработаны все сообщения
+// до него, иначе после перезапуска незавершенные сообщения потеряются.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	inFlight []kafka.Message    // в порядке чтения из партиции
+	done     map[int64]struct{} // обработанные offset'ы, еще не дошедшие до коммита
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[int]*partitionOffsets),
+	}
+}
+
+// track регистрирует прочитанное сообщение; вызывается в порядке чтения
+func (t *offsetTracker) track(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[msg.Partition]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]struct{})}
+		t.partitions[msg.Partition] = p
+	}
+	p.inFlight = append(p.inFlight, msg)
+}
+
+// markDone отмечает сообщение обработанным и возвращает последнее сообщение
+// непрерывного обработанного префикса партиции, если он сдвинулся
+func (t *offsetTracker) markDone(msg kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[msg.Partition]
+	if !ok {
+		return kafka.Message{}, false
+	}
+	p.done[msg.Offset] = struct{}{}
+
+	var commit kafka.Message
+	advanced := false
+	for len(p.inFlight) > 0 {
+		head := p.inFlight[0]
+		if _, ok := p.done[head.Offset]; !ok {
+			break
+		}
+		delete(p.done, head.Offset)
+		p.inFlight = p.inFlight[1:]
+		commit = head
+		advanced = true
+	}
+
+	return commit, advanced
+}
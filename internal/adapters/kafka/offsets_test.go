@@ -0,0 +1,115 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestOffsetTrackerMarkDone(t *testing.T) {
+	type step struct {
+		partition int
+		offset    int64
+		commit    int64 // -1 - коммитить нечего
+	}
+
+	tests := []struct {
+		name  string
+		track map[int][]int64
+		steps []step
+	}{
+		{
+			name:  "in order",
+			track: map[int][]int64{0: {10, 11, 12}},
+			steps: []step{
+				{0, 10, 10},
+				{0, 11, 11},
+				{0, 12, 12},
+			},
+		},
+		{
+			name:  "out of order completion waits for the head",
+			track: map[int][]int64{0: {10, 11, 12}},
+			steps: []step{
+				{0, 12, -1},
+				{0, 11, -1},
+				{0, 10, 12},
+			},
+		},
+		{
+			name:  "gap in the middle",
+			track: map[int][]int64{0: {1, 2, 3, 4}},
+			steps: []step{
+				{0, 1, 1},
+				{0, 3, -1},
+				{0, 4, -1},
+				{0, 2, 4},
+			},
+		},
+		{
+			name:  "sparse offsets after compaction",
+			track: map[int][]int64{0: {5, 9, 20}},
+			steps: []step{
+				{0, 9, -1},
+				{0, 5, 9},
+				{0, 20, 20},
+			},
+		},
+		{
+			name:  "partitions are independent",
+			track: map[int][]int64{0: {1, 2}, 1: {1, 2}},
+			steps: []step{
+				{1, 2, -1},
+				{0, 1, 1},
+				{1, 1, 2},
+				{0, 2, 2},
+			},
+		},
+		{
+			name:  "unknown partition",
+			track: map[int][]int64{0: {1}},
+			steps: []step{
+				{3, 1, -1},
+				{0, 1, 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOffsetTracker()
+			for partition, offsets := range tt.track {
+				for _, offset := range offsets {
+					tracker.track(kafka.Message{Partition: partition, Offset: offset})
+				}
+			}
+
+			for i, s := range tt.steps {
+				msg, ok := tracker.markDone(kafka.Message{Partition: s.partition, Offset: s.offset})
+				switch {
+				case s.commit < 0 && ok:
+					t.Fatalf("step %d: unexpected commit of offset %d", i, msg.Offset)
+				case s.commit >= 0 && !ok:
+					t.Fatalf("step %d: expected commit of offset %d, got none", i, s.commit)
+				case s.commit >= 0 && (msg.Offset != s.commit || msg.Partition != s.partition):
+					t.Fatalf("step %d: commit %d/%d, want %d/%d", i, msg.Partition, msg.Offset, s.partition, s.commit)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetTrackerDrainsPartition(t *testing.T) {
+	tracker := newOffsetTracker()
+	for offset := int64(0); offset < 3; offset++ {
+		tracker.track(kafka.Message{Offset: offset})
+	}
+	for offset := int64(2); offset >= 0; offset-- {
+		tracker.markDone(kafka.Message{Offset: offset})
+	}
+
+	p := tracker.partitions[0]
+	if len(p.inFlight) != 0 || len(p.done) != 0 {
+		t.Fatalf("partition not drained: %d in flight, %d done", len(p.inFlight), len(p.done))
+	}
+}
@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -22,7 +23,7 @@ func NewProducer(brokers []string, topic string) *Producer {
 	writer := &kafka.Writer{
 		Addr:         kafka.TCP(brokers...),
 		Topic:        topic,
-		Balancer:     &kafka.LeastBytes{},
+		Balancer:     &kafka.Hash{},    // события одного товара по ключу попадают в одну партицию
 		RequiredAcks: kafka.RequireAll, // exactly-once
 		MaxAttempts:  3,
 		BatchSize:    100,
@@ -50,7 +51,7 @@ func NewProducerWithAuth(brokers []string, topic, username, password string) *Pr
 	writer := kafka.WriterConfig{
 		Brokers:  brokers,
 		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Balancer: &kafka.Hash{},
 		Dialer:   dialer,
 	}
 
@@ -61,17 +62,63 @@ func NewProducerWithAuth(brokers []string, topic, username, password string) *Pr
 	}
 }
 
+// SendProductEvent публикует событие. ProducerID и Sequence задает источник
+// события (Sequence выдает outbox при записи): по Sequence процессор
+// отбрасывает устаревшие события, поэтому продюсер их не перезаписывает.
 func (p *Producer) SendProductEvent(ctx context.Context, event *models.ProductEvent) error {
-	event.ProducerID = p.producerID
-	event.Sequence = time.Now().UnixNano()
+	msg, err := p.message(event)
+	if err != nil {
+		return err
+	}
+
+	err = p.writer.WriteMessages(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to write message to kafka: %w", err)
+	}
+
+	return nil
+}
+
+// SendProductEvents отправляет события одним WriteMessages. Если часть сообщений
+// не записана, возвращает models.PublishErrors с ошибками по позициям.
+func (p *Producer) SendProductEvents(ctx context.Context, events []*models.ProductEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafka.Message, len(events))
+	for i, event := range events {
+		msg, err := p.message(event)
+		if err != nil {
+			return err
+		}
+		msgs[i] = msg
+	}
+
+	err := p.writer.WriteMessages(ctx, msgs...)
+	if err == nil {
+		return nil
+	}
+
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) {
+		return models.PublishErrors(writeErrors)
+	}
+	return fmt.Errorf("failed to write messages to kafka: %w", err)
+}
+
+func (p *Producer) message(event *models.ProductEvent) (kafka.Message, error) {
+	if event.ProducerID == "" {
+		event.ProducerID = p.producerID
+	}
 
 	eventData, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return kafka.Message{}, fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(fmt.Sprintf("product-%d", event.ProductID)),
+	return kafka.Message{
+		Key:   []byte(event.Key()),
 		Value: eventData,
 		Headers: []kafka.Header{
 			{
@@ -84,14 +131,7 @@ func (p *Producer) SendProductEvent(ctx context.Context, event *models.ProductEv
 			},
 		},
 		Time: time.Now(),
-	}
-
-	err = p.writer.WriteMessages(ctx, msg)
-	if err != nil {
-		return fmt.Errorf("failed to write message to kafka: %w", err)
-	}
-
-	return nil
+	}, nil
 }
 
 func (p *Producer) Close() error {
@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// RetryTier - топик повторной обработки и задержка перед повтором
+type RetryTier struct {
+	Topic string
+	Delay time.Duration
+}
+
+// RetryPolicy описывает путь упавшего сообщения: по очереди через все
+// retry-топики, затем в DLQ. Ошибки, которые повтор не исправит
+// (битый JSON, неизвестный тип события), сразу уходят в DLQ.
+type RetryPolicy struct {
+	Tiers    []RetryTier
+	DLQTopic string
+}
+
+func NewRetryPolicy(topics []string, delays []time.Duration, dlqTopic string) (RetryPolicy, error) {
+	if len(topics) != len(delays) {
+		return RetryPolicy{}, fmt.Errorf("retry topics and delays mismatch: %d topics, %d delays", len(topics), len(delays))
+	}
+	if dlqTopic == "" {
+		return RetryPolicy{}, fmt.Errorf("dlq topic is required")
+	}
+
+	policy := RetryPolicy{DLQTopic: dlqTopic}
+	for i, topic := range topics {
+		policy.Tiers = append(policy.Tiers, RetryTier{Topic: topic, Delay: delays[i]})
+	}
+
+	return policy, nil
+}
+
+// Заголовки, которые добавляются к сообщениям в retry-топиках и DLQ
+const (
+	headerRetryAttempt      = "retry_attempt"
+	headerOriginalTopic     = "original_topic"
+	headerOriginalPartition = "original_partition"
+	headerOriginalOffset    = "original_offset"
+	headerError             = "error"
+	headerErrorClass        = "error_class"
+	headerFailedAt          = "failed_at"
+)
+
+// permanentError - ошибка, которую повтор не исправит
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// handleFailure перекладывает упавшее сообщение в следующий retry-топик или DLQ.
+// tier - номер уровня, с которого прочитано сообщение (0 - основной топик).
+// Ошибка возвращается только если переложить не удалось: тогда сообщение не коммитится.
+func (c *Consumer) handleFailure(ctx context.Context, msg kafka.Message, tier int, procErr error) error {
+	target := c.retry.DLQTopic
+	status := "dead_lettered"
+	errorClass := "transient"
+
+	switch {
+	case isPermanent(procErr):
+		errorClass = "permanent"
+	case tier < len(c.retry.Tiers):
+		target = c.retry.Tiers[tier].Topic
+		status = "retried"
+	}
+
+	out := kafka.Message{
+		Topic:   target,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: failureHeaders(msg, tier, procErr, errorClass),
+		Time:    time.Now(),
+	}
+
+	if err := c.retryWriter.WriteMessages(ctx, out); err != nil {
+		return fmt.Errorf("failed to publish message to %s: %w", target, err)
+	}
+
+	metrics.RecordKafkaMessageProcessed(msg.Topic, status)
+	if target == c.retry.DLQTopic {
+		if id := eventIDOf(msg); id != "" {
+			c.completeOperations(ctx, models.OperationFailed, procErr.Error(), id)
+		}
+	}
+	fmt.Printf("Message %s/%d/%d moved to %s: %v\n", msg.Topic, msg.Partition, msg.Offset, target, procErr)
+
+	return nil
+}
+
+// failureHeaders сохраняет заголовки исходного сообщения и заменяет служебные.
+// original_* указывают на самое первое место сообщения, даже после нескольких повторов.
+func failureHeaders(msg kafka.Message, tier int, procErr error, errorClass string) []kafka.Header {
+	original := map[string]string{
+		headerOriginalTopic:     msg.Topic,
+		headerOriginalPartition: strconv.Itoa(msg.Partition),
+		headerOriginalOffset:    strconv.FormatInt(msg.Offset, 10),
+	}
+
+	headers := make([]kafka.Header, 0, len(msg.Headers)+7)
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case headerOriginalTopic, headerOriginalPartition, headerOriginalOffset:
+			original[h.Key] = string(h.Value)
+		case headerRetryAttempt, headerError, headerErrorClass, headerFailedAt:
+		default:
+			headers = append(headers, h)
+		}
+	}
+
+	return append(headers,
+		kafka.Header{Key: headerOriginalTopic, Value: []byte(original[headerOriginalTopic])},
+		kafka.Header{Key: headerOriginalPartition, Value: []byte(original[headerOriginalPartition])},
+		kafka.Header{Key: headerOriginalOffset, Value: []byte(original[headerOriginalOffset])},
+		kafka.Header{Key: headerRetryAttempt, Value: []byte(strconv.Itoa(tier + 1))},
+		kafka.Header{Key: headerError, Value: []byte(procErr.Error())},
+		kafka.Header{Key: headerErrorClass, Value: []byte(errorClass)},
+		kafka.Header{Key: headerFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+}
+
+// waitForRetry ждет, пока с момента попадания сообщения в retry-топик пройдет delay.
+// Сообщения в партиции идут по времени, поэтому ожидание блокирует только этот уровень.
+func waitForRetry(ctx context.Context, msg kafka.Message, delay time.Duration) error {
+	wait := time.Until(msg.Time.Add(delay))
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
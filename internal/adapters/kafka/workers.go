@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// workerPool раздает сообщения воркерам по хешу ключа: сообщения с одним
+// ключом (product-<id>) обрабатываются строго по очереди, разные ключи - параллельно
+type workerPool struct {
+	queues []chan kafka.Message
+	wg     sync.WaitGroup
+}
+
+func newWorkerPool(workers, queueSize int) *workerPool {
+	queues := make([]chan kafka.Message, workers)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, queueSize)
+	}
+
+	return &workerPool{
+		queues: queues,
+	}
+}
+
+func (p *workerPool) start(handle func(msg kafka.Message)) {
+	for _, queue := range p.queues {
+		p.wg.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer p.wg.Done()
+			for msg := range queue {
+				handle(msg)
+			}
+		}(queue)
+	}
+}
+
+// dispatch блокируется, пока у нужного воркера нет места в очереди
+func (p *workerPool) dispatch(ctx context.Context, msg kafka.Message) error {
+	select {
+	case p.queues[p.index(msg)] <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop закрывает очереди и ждет, пока воркеры разберут уже принятые сообщения
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+func (p *workerPool) index(msg kafka.Message) int {
+	if len(msg.Key) == 0 {
+		return msg.Partition % len(p.queues)
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write(msg.Key)
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
+)
+
+// ProductCache - локальный LRU-кеш карточек товаров перед общим кешем (Redis).
+// Карточки живут в памяти не дольше ttl; изменения, примененные процессором,
+// приходят через Invalidate. Списки и агрегаты не кешируются локально и
+// читаются из общего кеша.
+type ProductCache struct {
+	shared repositories.ProductCache // общий кеш, в который пишутся все изменения
+
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // в начале - недавно прочитанные
+	epoch uint64     // растет с каждой инвалидацией
+
+	// misses - epoch на момент промаха или устаревшего чтения по ключу;
+	// по нему Set решает, можно ли запомнить загруженное значение
+	misses map[string]uint64
+}
+
+type entry struct {
+	key       string
+	value     *models.CachedProduct
+	expiresAt time.Time
+}
+
+func NewProductCache(shared repositories.ProductCache, size int, ttl time.Duration) *ProductCache {
+	return &ProductCache{
+		shared: shared,
+		size:   size,
+		ttl:    ttl,
+		items:  make(map[string]*list.Element, size),
+		lru:    list.New(),
+		misses: make(map[string]uint64),
+	}
+}
+
+func (c *ProductCache) Get(ctx context.Context, key string) (*models.CachedProduct, error) {
+	c.mu.Lock()
+	if value, ok := c.lookup(key); ok {
+		c.mu.Unlock()
+		metrics.RecordCacheRequest("product_local", true)
+		return value, nil
+	}
+	epoch := c.epoch
+	c.mu.Unlock()
+	metrics.RecordCacheRequest("product_local", false)
+
+	value, err := c.shared.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Устаревшую запись и промах не запоминаем: GetProduct загрузит товар и
+	// вызовет Set, который сверит epoch промаха. Если за время чтения пришла
+	// инвалидация, прочитанное значение могло устареть, и его тоже не запоминаем.
+	c.mu.Lock()
+	switch {
+	case value == nil || value.Stale:
+		if len(c.misses) >= c.size {
+			// Загрузки, не дошедшие до Set (ошибка БД), не копятся
+			clear(c.misses)
+		}
+		c.misses[key] = epoch
+	case c.epoch == epoch:
+		c.store(key, value)
+	}
+	c.mu.Unlock()
+
+	return value, nil
+}
+
+// Set и SetNotFound запоминают значение локально с той же проверкой epoch,
+// что и Get: загрузка из БД, закончившаяся после инвалидации, могла прочитать
+// уже устаревшие данные.
+func (c *ProductCache) Set(ctx context.Context, key string, product *models.Product) error {
+	if err := c.shared.Set(ctx, key, product); err != nil {
+		return err
+	}
+
+	c.storeLoaded(key, &models.CachedProduct{Product: product})
+	return nil
+}
+
+func (c *ProductCache) SetNotFound(ctx context.Context, key string) error {
+	if err := c.shared.SetNotFound(ctx, key); err != nil {
+		return err
+	}
+
+	c.storeLoaded(key, &models.CachedProduct{NotFound: true})
+	return nil
+}
+
+// storeLoaded запоминает результат загрузки, начатой промахом Get, если с
+// момента промаха не было инвалидаций. Без записи о промахе (например, запись
+// пришла не из загрузки) значение только уходит в общий кеш.
+func (c *ProductCache) storeLoaded(key string, value *models.CachedProduct) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	epoch, ok := c.misses[key]
+	if !ok {
+		return
+	}
+	delete(c.misses, key)
+	if epoch == c.epoch {
+		c.store(key, value)
+	}
+}
+
+// SetMany пишет только в общий кеш. Общий кеш заполняет лишь пустые ключи,
+// поэтому локальные копии остаются верными.
+func (c *ProductCache) SetMany(ctx context.Context, products map[string]*models.Product) error {
+	return c.shared.SetMany(ctx, products)
+}
+
+// SetDeleted сбрасывает локальную копию после записи надгробия в общий кеш:
+// Get, прочитавший карточку до надгробия, не запомнит ее из-за смены epoch
+func (c *ProductCache) SetDeleted(ctx context.Context, key string, version int64) error {
+	err := c.shared.SetDeleted(ctx, key, version)
+	c.Invalidate([]string{key})
+	return err
+}
+
+func (c *ProductCache) Delete(ctx context.Context, key string) error {
+	c.Invalidate([]string{key})
+	return c.shared.Delete(ctx, key)
+}
+
+func (c *ProductCache) SetList(ctx context.Context, key string, products []*models.Product) error {
+	return c.shared.SetList(ctx, key, products)
+}
+
+func (c *ProductCache) GetList(ctx context.Context, key string) ([]*models.Product, error) {
+	return c.shared.GetList(ctx, key)
+}
+
+func (c *ProductCache) SetFacets(ctx context.Context, key string, facets *models.ProductFacets) error {
+	return c.shared.SetFacets(ctx, key, facets)
+}
+
+func (c *ProductCache) GetFacets(ctx context.Context, key string) (*models.ProductFacets, error) {
+	return c.shared.GetFacets(ctx, key)
+}
+
+func (c *ProductCache) SetCount(ctx context.Context, key string, count int) error {
+	return c.shared.SetCount(ctx, key, count)
+}
+
+func (c *ProductCache) GetCount(ctx context.Context, key string) (int, bool, error) {
+	return c.shared.GetCount(ctx, key)
+}
+
+func (c *ProductCache) ListGeneration(ctx context.Context) (int64, error) {
+	return c.shared.ListGeneration(ctx)
+}
+
+func (c *ProductCache) InvalidateLists(ctx context.Context) error {
+	return c.shared.InvalidateLists(ctx)
+}
+
+// Invalidate убирает ключи из локального кеша; вызывается подписчиком
+// на рассылку инвалидаций
+func (c *ProductCache) Invalidate(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// lookup и store вызываются под mu
+func (c *ProductCache) lookup(key string) (*models.CachedProduct, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if time.Now().After(e.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *ProductCache) store(key string, value *models.CachedProduct) {
+	expiresAt := time.Now().Add(c.ttl)
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		e.value = value
+		e.expiresAt = expiresAt
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *ProductCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
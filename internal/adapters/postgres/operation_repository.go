@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+
+	"github.com/lib/pq"
+)
+
+type OperationRepository struct {
+	db *sql.DB
+}
+
+func NewOperationRepository(db *sql.DB) *OperationRepository {
+	return &OperationRepository{
+		db: db,
+	}
+}
+
+func (r *OperationRepository) Create(ctx context.Context, operation *models.Operation) error {
+	query := `
+		INSERT INTO operations (id, event_type, product_id, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		operation.ID,
+		string(operation.EventType),
+		operation.ProductID,
+		string(operation.Status),
+	).Scan(&operation.CreatedAt, &operation.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	return nil
+}
+
+// CreateBatch заводит операции пакетного запроса одним INSERT
+func (r *OperationRepository) CreateBatch(ctx context.Context, operations []*models.Operation) error {
+	return insertOperations(ctx, r.db, operations)
+}
+
+// insertOperations - общий INSERT операций для CreateBatch и
+// OutboxRepository.AddWithOperations
+func insertOperations(ctx context.Context, q dbtx, operations []*models.Operation) error {
+	if len(operations) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(operations))
+	eventTypes := make([]string, len(operations))
+	productIDs := make([]int64, len(operations))
+	statuses := make([]string, len(operations))
+	for i, operation := range operations {
+		ids[i] = operation.ID
+		eventTypes[i] = string(operation.EventType)
+		productIDs[i] = int64(operation.ProductID)
+		statuses[i] = string(operation.Status)
+	}
+
+	query := `
+		INSERT INTO operations (id, event_type, product_id, status)
+		SELECT * FROM unnest($1::varchar[], $2::varchar[], $3::bigint[], $4::varchar[])
+		RETURNING id, created_at, updated_at
+	`
+
+	rows, err := q.QueryContext(ctx, query,
+		pq.Array(ids), pq.Array(eventTypes), pq.Array(productIDs), pq.Array(statuses))
+	if err != nil {
+		return fmt.Errorf("failed to create operations: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	byID := make(map[string]*models.Operation, len(operations))
+	for _, operation := range operations {
+		byID[operation.ID] = operation
+	}
+	for rows.Next() {
+		var id string
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&id, &createdAt, &updatedAt); err != nil {
+			return fmt.Errorf("failed to scan operation: %w", err)
+		}
+		if operation, ok := byID[id]; ok {
+			operation.CreatedAt = createdAt
+			operation.UpdatedAt = updatedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *OperationRepository) GetByID(ctx context.Context, id string) (*models.Operation, error) {
+	query := `
+		SELECT id, event_type, product_id, status, COALESCE(error, ''), created_at, updated_at
+		FROM operations
+		WHERE id = $1
+	`
+
+	var operation models.Operation
+	var eventType, status string
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&operation.ID,
+		&eventType,
+		&operation.ProductID,
+		&status,
+		&operation.Error,
+		&operation.CreatedAt,
+		&operation.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrOperationNotFound
+		}
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+	operation.EventType = models.EventType(eventType)
+	operation.Status = models.OperationStatus(status)
+
+	return &operation, nil
+}
+
+// UpdateStatus переводит операции в итоговый статус. Уже завершенные операции
+// не меняются, чтобы повторная доставка события не перезаписала результат.
+func (r *OperationRepository) UpdateStatus(ctx context.Context, status models.OperationStatus, reason string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		UPDATE operations
+		SET status = $1, error = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = ANY($3::varchar[]) AND status = 'pending'
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, string(status), reason, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to update operation status: %w", err)
+	}
+
+	return nil
+}
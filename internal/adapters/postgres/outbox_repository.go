@@ -0,0 +1,275 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+
+	"github.com/lib/pq"
+)
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+func (r *OutboxRepository) Add(ctx context.Context, event *models.ProductEvent) error {
+	return insertOutboxEvents(ctx, r.db, []*models.ProductEvent{event})
+}
+
+// AddBatch сохраняет события пакетного запроса одним INSERT; порядок id
+// совпадает с порядком событий, поэтому relay опубликует их в том же порядке
+func (r *OutboxRepository) AddBatch(ctx context.Context, events []*models.ProductEvent) error {
+	return insertOutboxEvents(ctx, r.db, events)
+}
+
+// AddWithOperations сохраняет события вместе с их операциями одной транзакцией:
+// операция не может остаться в pending без события в outbox
+func (r *OutboxRepository) AddWithOperations(ctx context.Context, events []*models.ProductEvent, operations []*models.Operation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := insertOperations(ctx, tx, operations); err != nil {
+		return err
+	}
+	if err := insertOutboxEvents(ctx, tx, events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertOutboxEvents сохраняет события в порядке передачи. Sequence события
+// выдается базой: это id строки outbox, он же записывается в payload. Так
+// порядок изменений товара не зависит от часов реплик API.
+func insertOutboxEvents(ctx context.Context, q dbtx, events []*models.ProductEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	eventIDs := make([]string, len(events))
+	eventTypes := make([]string, len(events))
+	keys := make([]string, len(events))
+	payloads := make([]string, len(events))
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		eventIDs[i] = event.EventID
+		eventTypes[i] = string(event.EventType)
+		keys[i] = event.Key()
+		payloads[i] = string(payload)
+	}
+
+	// nextval в списке выборки вычисляется после ORDER BY, поэтому id
+	// возрастают в порядке событий
+	query := `
+		WITH numbered AS (
+			SELECT nextval(pg_get_serial_sequence('product_outbox', 'id')) AS id,
+				event_id, event_type, aggregate_key, payload::jsonb AS payload
+			FROM unnest($1::varchar[], $2::varchar[], $3::varchar[], $4::text[])
+				WITH ORDINALITY AS e(event_id, event_type, aggregate_key, payload, n)
+			ORDER BY n
+		)
+		INSERT INTO product_outbox (id, event_id, event_type, aggregate_key, payload)
+		SELECT id, event_id, event_type, aggregate_key,
+			jsonb_set(payload, '{sequence}', to_jsonb(id))
+		FROM numbered
+		RETURNING event_id, id
+	`
+
+	rows, err := q.QueryContext(ctx, query,
+		pq.Array(eventIDs), pq.Array(eventTypes), pq.Array(keys), pq.Array(payloads))
+	if err != nil {
+		return fmt.Errorf("failed to add events to outbox: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	byID := make(map[string]*models.ProductEvent, len(events))
+	for _, event := range events {
+		byID[event.EventID] = event
+	}
+	for rows.Next() {
+		var eventID string
+		var sequence int64
+		if err := rows.Scan(&eventID, &sequence); err != nil {
+			return fmt.Errorf("failed to scan outbox sequence: %w", err)
+		}
+		if event, ok := byID[eventID]; ok {
+			event.Sequence = sequence
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// outboxClaimLock - ключ advisory lock, под которым relay'и по очереди
+// захватывают сообщения
+const outboxClaimLock = 0x6f7574626f78
+
+// FetchPending захватывает готовые к отправке сообщения в порядке записи:
+// next_attempt_at сдвигается на lease, и до его истечения сообщения не выдаются
+// другим relay'ям. Сообщение не выдается, пока более раннее сообщение с тем же
+// ключом ждет повтора или захвачено, поэтому события одного товара публикуются
+// строго по очереди. Захват идет под общим advisory lock, иначе два relay'я с
+// одним снимком могли бы разобрать соседние события одного товара.
+func (r *OutboxRepository) FetchPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxMessage, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, outboxClaimLock); err != nil {
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+
+	query := `
+		WITH claimed AS (
+			SELECT o.id
+			FROM product_outbox o
+			WHERE o.sent_at IS NULL
+				AND o.next_attempt_at <= NOW()
+				AND NOT EXISTS (
+					SELECT 1 FROM product_outbox p
+					WHERE p.aggregate_key = o.aggregate_key
+						AND p.sent_at IS NULL
+						AND p.next_attempt_at > NOW()
+						AND p.id < o.id
+				)
+			ORDER BY o.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE product_outbox o
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM claimed
+		WHERE o.id = claimed.id
+		RETURNING o.id, o.event_id, o.event_type, o.aggregate_key, o.payload,
+			o.attempts, COALESCE(o.last_error, ''), o.next_attempt_at, o.created_at
+	`
+
+	rows, err := tx.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch outbox messages: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			panic(err)
+		}
+	}(rows)
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		var msg models.OutboxMessage
+		var eventType string
+
+		err := rows.Scan(
+			&msg.ID,
+			&msg.EventID,
+			&eventType,
+			&msg.AggregateKey,
+			&msg.Payload,
+			&msg.Attempts,
+			&msg.LastError,
+			&msg.NextAttemptAt,
+			&msg.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		msg.EventType = models.EventType(eventType)
+
+		messages = append(messages, &msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	slices.SortFunc(messages, func(a, b *models.OutboxMessage) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return messages, nil
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `UPDATE product_outbox SET sent_at = NOW(), last_error = NULL WHERE id = ANY($1::bigint[])`
+
+	if _, err := r.db.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to mark outbox message as sent: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed не трогает уже отправленное сообщение: если lease истек и его
+// успел опубликовать другой relay, запоздалая ошибка не вернет его в очередь
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, reason string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE product_outbox
+		SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2
+		WHERE id = $3 AND sent_at IS NULL
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, reason, nextAttemptAt, id); err != nil {
+		return fmt.Errorf("failed to mark outbox message as failed: %w", err)
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) DeleteSentBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM product_outbox WHERE sent_at IS NOT NULL AND sent_at < $1`
+
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent outbox messages: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
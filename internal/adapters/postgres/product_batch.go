@@ -0,0 +1,502 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+
+	"github.com/lib/pq"
+)
+
+// ApplyBatch применяет пачку событий одной транзакцией: повторы отсекаются по
+// processed_events, порядок проверяется по product_sequences, несколько событий
+// одного товара схлопываются в последнее,
+// а вставки, обновления и удаления выполняются многострочными запросами.
+// Возвращает фактически примененные события в исходном порядке.
+func (r *ProductRepository) ApplyBatch(ctx context.Context, events []*models.ProductEvent) ([]*models.ProductEvent, error) {
+	for _, event := range events {
+		if event.EventType == models.ProductPatched {
+			return nil, errPatchInBatch
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	fresh, err := markEventsProcessed(ctx, tx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := advanceSequences(ctx, tx, fresh); err != nil {
+		return nil, err
+	}
+
+	applied, err := collapseEvents(fresh)
+	if err != nil {
+		return nil, err
+	}
+
+	var creates, updates []*models.Product
+	var updateVersions, deletes, deleteVersions []int64
+	for _, event := range applied {
+		switch event.EventType {
+		case models.ProductCreated:
+			creates = append(creates, event.ProductData)
+		case models.ProductUpdated:
+			updates = append(updates, event.ProductData)
+			updateVersions = append(updateVersions, event.Version)
+		case models.ProductDeleted:
+			deletes = append(deletes, int64(event.ProductID))
+			deleteVersions = append(deleteVersions, event.Version)
+		default:
+			return nil, fmt.Errorf("unknown event type: %s", event.EventType)
+		}
+	}
+
+	if err := insertProducts(ctx, tx, creates); err != nil {
+		return nil, err
+	}
+	if err := updateProducts(ctx, tx, updates, updateVersions); err != nil {
+		return nil, err
+	}
+	deleted, err := deleteProducts(ctx, tx, deletes, deleteVersions)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range applied {
+		if version, ok := deleted[event.ProductID]; ok && event.EventType == models.ProductDeleted {
+			event.ProductData = deletedProduct(event.ProductID, version)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return applied, nil
+}
+
+// markEventsProcessed записывает EventID пачки и возвращает только новые события
+func markEventsProcessed(ctx context.Context, q dbtx, events []*models.ProductEvent) ([]*models.ProductEvent, error) {
+	ids := make([]string, len(events))
+	types := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.EventID
+		types[i] = string(event.EventType)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		INSERT INTO processed_events (event_id, event_type)
+		SELECT * FROM unnest($1::varchar[], $2::varchar[])
+		ON CONFLICT (event_id) DO NOTHING
+		RETURNING event_id
+	`, pq.Array(ids), pq.Array(types))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record processed events: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	inserted := make(map[string]bool, len(events))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan event id: %w", err)
+		}
+		inserted[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	fresh := make([]*models.ProductEvent, 0, len(inserted))
+	for _, event := range events {
+		// Один и тот же EventID внутри пачки применяется один раз
+		if inserted[event.EventID] {
+			fresh = append(fresh, event)
+			delete(inserted, event.EventID)
+		}
+	}
+
+	return fresh, nil
+}
+
+// errStaleInBatch - в пачке есть событие старше уже примененного; такие события
+// разбираются поштучно, чтобы у каждой операции был свой итог
+var errStaleInBatch = errors.New("batch contains events older than the last applied change")
+
+// advanceSequences - пакетный вариант advanceSequence. Для каждого товара
+// проверяется, что первое событие пачки новее сохраненного, а события внутри
+// пачки идут по возрастанию Sequence.
+func advanceSequences(ctx context.Context, q dbtx, events []*models.ProductEvent) error {
+	position := make(map[int]int)
+	var ids, firsts, lasts []int64
+	for _, event := range events {
+		id := eventProductID(event)
+		if id == 0 || event.Sequence == 0 {
+			continue
+		}
+
+		i, seen := position[id]
+		if !seen {
+			position[id] = len(ids)
+			ids = append(ids, int64(id))
+			firsts = append(firsts, event.Sequence)
+			lasts = append(lasts, event.Sequence)
+			continue
+		}
+		if event.Sequence <= lasts[i] {
+			return errStaleInBatch
+		}
+		lasts[i] = event.Sequence
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	result, err := q.ExecContext(ctx, `
+		WITH v AS (
+			SELECT * FROM unnest($1::bigint[], $2::bigint[], $3::bigint[])
+				AS v(product_id, first_sequence, last_sequence)
+		)
+		INSERT INTO product_sequences (product_id, last_sequence)
+		SELECT product_id, last_sequence FROM v
+		ON CONFLICT (product_id) DO UPDATE
+			SET last_sequence = EXCLUDED.last_sequence, updated_at = NOW()
+			WHERE product_sequences.last_sequence <
+				(SELECT first_sequence FROM v WHERE v.product_id = EXCLUDED.product_id)
+	`, pq.Array(ids), pq.Array(firsts), pq.Array(lasts))
+	if err != nil {
+		return fmt.Errorf("failed to advance product sequences: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected != int64(len(ids)) {
+		return errStaleInBatch
+	}
+
+	return nil
+}
+
+// errPatchInBatch - патч применяется к текущей строке с проверкой результата,
+// поэтому пачки с product_patched разбираются поштучно
+var errPatchInBatch = errors.New("batch contains product_patched events")
+
+// errConditionalChain - в пачке есть условное (If-Match) событие после другого
+// события того же товара; проверить его версию можно только поштучно
+var errConditionalChain = errors.New("batch contains conditional event after another event for the same product")
+
+// collapseEvents сводит несколько событий одного товара в одно:
+// создание + обновление дают создание с последними данными, создание + удаление
+// не дают ничего, в остальных случаях побеждает последнее событие с ожидаемой
+// версией первого. События без ID товара схлопнуть нельзя, они сохраняются все.
+func collapseEvents(events []*models.ProductEvent) ([]*models.ProductEvent, error) {
+	merged := make(map[int]*models.ProductEvent, len(events))
+	position := make(map[int]int, len(events))
+	collapsed := make([]*models.ProductEvent, 0, len(events))
+
+	for _, event := range events {
+		id := eventProductID(event)
+		if id == 0 {
+			collapsed = append(collapsed, event)
+			continue
+		}
+
+		prev, seen := merged[id]
+		if !seen {
+			merged[id] = event
+			position[id] = len(collapsed)
+			collapsed = append(collapsed, event)
+			continue
+		}
+
+		if event.Version != 0 {
+			return nil, errConditionalChain
+		}
+
+		next := event
+		if prev != nil && prev.Version != 0 {
+			chained := *event
+			chained.Version = prev.Version
+			next = &chained
+		}
+		if prev != nil && prev.EventType == models.ProductCreated {
+			switch event.EventType {
+			case models.ProductUpdated:
+				created := *event
+				created.EventType = models.ProductCreated
+				created.Version = 0
+				next = &created
+			case models.ProductDeleted:
+				next = nil
+			}
+		}
+		merged[id] = next
+		collapsed[position[id]] = next
+	}
+
+	result := collapsed[:0]
+	for _, event := range collapsed {
+		if event != nil {
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
+
+func eventProductID(event *models.ProductEvent) int {
+	if event.ProductData != nil && event.ProductData.ID != 0 {
+		return event.ProductData.ID
+	}
+	return event.ProductID
+}
+
+// insertProducts вставляет товары с заранее известными ID, поэтому
+// соответствие строк и товаров не зависит от порядка RETURNING
+func insertProducts(ctx context.Context, q dbtx, products []*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	var now time.Time
+	if err := q.QueryRowContext(ctx, `SELECT NOW()`).Scan(&now); err != nil {
+		return fmt.Errorf("failed to get transaction time: %w", err)
+	}
+
+	// ID обычно уже зарезервирован в API, недостающие берутся из последовательности
+	missing := 0
+	for _, product := range products {
+		if product.ID == 0 {
+			missing++
+		}
+	}
+	ids, err := reserveProductIDs(ctx, q, missing)
+	if err != nil {
+		return err
+	}
+
+	cols := newProductColumns(len(products))
+	for _, product := range products {
+		if product.ID == 0 {
+			product.ID = int(ids[0])
+			ids = ids[1:]
+		}
+		product.Version = 1
+		product.CreatedAt = now
+		product.UpdatedAt = now
+		if err := cols.add(product); err != nil {
+			return err
+		}
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO products (id, name, weight, unit, color, type, price, attributes, created_at, updated_at)
+		SELECT v.id, v.name, v.weight, v.unit, v.color, v.type, v.price, v.attributes, $9, $9
+		FROM unnest($1::bigint[], $2::varchar[], $3::numeric[], $4::varchar[], $5::varchar[],
+			$6::product_type[], $7::numeric[], $8::jsonb[])
+			AS v(id, name, weight, unit, color, type, price, attributes)
+	`, append(cols.args(), now)...)
+	if err != nil {
+		return fmt.Errorf("failed to insert products: %w", err)
+	}
+
+	return nil
+}
+
+func reserveProductIDs(ctx context.Context, q dbtx, n int) ([]int64, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT nextval(pg_get_serial_sequence('products', 'id')) FROM generate_series(1, $1)`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve product ids: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan product id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+// updateProducts обновляет все товары одним запросом с проверкой ожидаемых версий
+// (0 - без проверки). Если какой-то товар не обновился, транзакция откатывается.
+func updateProducts(ctx context.Context, q dbtx, products []*models.Product, expectedVersions []int64) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	cols := newProductColumns(len(products))
+	for _, product := range products {
+		if err := cols.add(product); err != nil {
+			return err
+		}
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		UPDATE products p
+		SET name = v.name, weight = v.weight, unit = v.unit, color = v.color, type = v.type,
+			price = v.price, attributes = v.attributes, version = p.version + 1, updated_at = NOW()
+		FROM unnest($1::bigint[], $2::varchar[], $3::numeric[], $4::varchar[], $5::varchar[],
+			$6::product_type[], $7::numeric[], $8::jsonb[], $9::bigint[])
+			AS v(id, name, weight, unit, color, type, price, attributes, expected_version)
+		WHERE p.id = v.id AND (v.expected_version = 0 OR p.version = v.expected_version)
+		RETURNING p.id, p.version, p.created_at, p.updated_at
+	`, append(cols.args(), pq.Array(expectedVersions))...)
+	if err != nil {
+		return fmt.Errorf("failed to update products: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	byID := make(map[int]*models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	updated := 0
+	for rows.Next() {
+		var id int
+		var version int64
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&id, &version, &createdAt, &updatedAt); err != nil {
+			return fmt.Errorf("failed to scan updated product: %w", err)
+		}
+		if product, ok := byID[id]; ok {
+			product.Version = version
+			product.CreatedAt = createdAt
+			product.UpdatedAt = updatedAt
+		}
+		updated++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if updated != len(products) {
+		return fmt.Errorf("updated %d of %d products: product is missing or its version changed", updated, len(products))
+	}
+
+	return nil
+}
+
+// deleteProducts удаляет товары одним запросом и возвращает версии удаленных строк
+func deleteProducts(ctx context.Context, q dbtx, ids, expectedVersions []int64) (map[int]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		DELETE FROM products p
+		USING unnest($1::bigint[], $2::bigint[]) AS v(id, expected_version)
+		WHERE p.id = v.id AND (v.expected_version = 0 OR p.version = v.expected_version)
+		RETURNING p.id, p.version
+	`, pq.Array(ids), pq.Array(expectedVersions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete products: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	deleted := make(map[int]int64, len(ids))
+	for rows.Next() {
+		var id int
+		var version int64
+		if err := rows.Scan(&id, &version); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted product: %w", err)
+		}
+		deleted[id] = version
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(deleted) != len(ids) {
+		return nil, fmt.Errorf("deleted %d of %d products: product is missing or its version changed", len(deleted), len(ids))
+	}
+
+	return deleted, nil
+}
+
+// productColumns раскладывает товары по массивам для unnest
+type productColumns struct {
+	ids        []int64
+	names      []string
+	weights    []float64
+	units      []string
+	colors     []string
+	types      []string
+	prices     []float64
+	attributes []string
+}
+
+func newProductColumns(n int) *productColumns {
+	return &productColumns{
+		ids:        make([]int64, 0, n),
+		names:      make([]string, 0, n),
+		weights:    make([]float64, 0, n),
+		units:      make([]string, 0, n),
+		colors:     make([]string, 0, n),
+		types:      make([]string, 0, n),
+		prices:     make([]float64, 0, n),
+		attributes: make([]string, 0, n),
+	}
+}
+
+func (c *productColumns) add(product *models.Product) error {
+	attributesJSON, err := json.Marshal(product.Attributes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attributes: %w", err)
+	}
+
+	c.ids = append(c.ids, int64(product.ID))
+	c.names = append(c.names, product.Name)
+	c.weights = append(c.weights, product.Weight)
+	c.units = append(c.units, product.Unit)
+	c.colors = append(c.colors, product.Color)
+	c.types = append(c.types, string(product.Type))
+	c.prices = append(c.prices, product.Price)
+	c.attributes = append(c.attributes, string(attributesJSON))
+	return nil
+}
+
+func (c *productColumns) args() []interface{} {
+	return []interface{}{
+		pq.Array(c.ids),
+		pq.Array(c.names),
+		pq.Array(c.weights),
+		pq.Array(c.units),
+		pq.Array(c.colors),
+		pq.Array(c.types),
+		pq.Array(c.prices),
+		pq.Array(c.attributes),
+	}
+}
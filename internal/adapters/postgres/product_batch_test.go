@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+func TestCollapseEvents(t *testing.T) {
+	created := func(id int, name string) *models.ProductEvent {
+		return &models.ProductEvent{
+			EventID:     "c-" + name,
+			EventType:   models.ProductCreated,
+			ProductID:   id,
+			ProductData: &models.Product{ID: id, Name: name},
+		}
+	}
+	updated := func(id int, name string, version int64) *models.ProductEvent {
+		return &models.ProductEvent{
+			EventID:     "u-" + name,
+			EventType:   models.ProductUpdated,
+			ProductID:   id,
+			ProductData: &models.Product{ID: id, Name: name},
+			Version:     version,
+		}
+	}
+	deleted := func(id int, version int64) *models.ProductEvent {
+		return &models.ProductEvent{
+			EventID:   "d",
+			EventType: models.ProductDeleted,
+			ProductID: id,
+			Version:   version,
+		}
+	}
+
+	// result - событие в виде тип/ID/имя/версия
+	type result struct {
+		eventType models.EventType
+		id        int
+		name      string
+		version   int64
+	}
+
+	tests := []struct {
+		name    string
+		events  []*models.ProductEvent
+		want    []result
+		wantErr error
+	}{
+		{
+			name:   "single events stay as is",
+			events: []*models.ProductEvent{created(1, "a"), updated(2, "b", 0), deleted(3, 0)},
+			want: []result{
+				{models.ProductCreated, 1, "a", 0},
+				{models.ProductUpdated, 2, "b", 0},
+				{models.ProductDeleted, 3, "", 0},
+			},
+		},
+		{
+			name:   "create and update give create with latest data",
+			events: []*models.ProductEvent{created(1, "a"), updated(1, "b", 0), updated(1, "c", 0)},
+			want:   []result{{models.ProductCreated, 1, "c", 0}},
+		},
+		{
+			name:   "create and delete cancel out",
+			events: []*models.ProductEvent{created(1, "a"), updated(1, "b", 0), deleted(1, 0)},
+			want:   []result{},
+		},
+		{
+			name:   "create and delete keep other products in order",
+			events: []*models.ProductEvent{updated(2, "x", 0), created(1, "a"), deleted(1, 0), updated(3, "y", 0)},
+			want: []result{
+				{models.ProductUpdated, 2, "x", 0},
+				{models.ProductUpdated, 3, "y", 0},
+			},
+		},
+		{
+			name:   "last update wins at the first position",
+			events: []*models.ProductEvent{updated(1, "a", 0), updated(2, "x", 0), updated(1, "b", 0)},
+			want: []result{
+				{models.ProductUpdated, 1, "b", 0},
+				{models.ProductUpdated, 2, "x", 0},
+			},
+		},
+		{
+			name:   "conditional head passes its version down the chain",
+			events: []*models.ProductEvent{updated(1, "a", 3), updated(1, "b", 0), deleted(1, 0)},
+			want:   []result{{models.ProductDeleted, 1, "", 3}},
+		},
+		{
+			name:    "conditional event after another event is rejected",
+			events:  []*models.ProductEvent{updated(1, "a", 0), updated(1, "b", 5)},
+			wantErr: errConditionalChain,
+		},
+		{
+			name:    "conditional delete after create is rejected",
+			events:  []*models.ProductEvent{created(1, "a"), deleted(1, 1)},
+			wantErr: errConditionalChain,
+		},
+		{
+			name: "events without product ID are all kept",
+			events: []*models.ProductEvent{
+				{EventType: models.ProductCreated, ProductData: &models.Product{Name: "a"}},
+				{EventType: models.ProductCreated, ProductData: &models.Product{Name: "b"}},
+			},
+			want: []result{
+				{models.ProductCreated, 0, "a", 0},
+				{models.ProductCreated, 0, "b", 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := collapseEvents(tt.events)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d events, want %d", len(got), len(tt.want))
+			}
+			for i, event := range got {
+				var name string
+				if event.ProductData != nil {
+					name = event.ProductData.Name
+				}
+				r := result{event.EventType, eventProductID(event), name, event.Version}
+				if r != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, r, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCollapseEventsDoesNotMutateInput(t *testing.T) {
+	head := &models.ProductEvent{EventType: models.ProductUpdated, ProductID: 1, Version: 2, ProductData: &models.Product{ID: 1}}
+	tail := &models.ProductEvent{EventType: models.ProductUpdated, ProductID: 1, ProductData: &models.Product{ID: 1}}
+
+	if _, err := collapseEvents([]*models.ProductEvent{head, tail}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tail.Version != 0 || tail.EventType != models.ProductUpdated {
+		t.Fatalf("input event changed: %+v", tail)
+	}
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// Facets считает одним запросом количество товаров по типам и цветам, диапазон
+// цен и гистограмму цен из buckets корзин. Limit, Offset, Sort и курсоры
+// фильтра не учитываются.
+func (r *ProductRepository) Facets(ctx context.Context, filter models.ProductFilter, buckets int) (*models.ProductFacets, error) {
+	conditions, args := filterConditions(filter)
+	query := fmt.Sprintf(`
+		WITH filtered AS (
+			SELECT type, color, price FROM products WHERE 1=1`+conditions+`
+		),
+		stats AS (
+			SELECT COUNT(*) AS total, MIN(price) AS min_price, MAX(price) AS max_price FROM filtered
+		)
+		SELECT
+			stats.total,
+			stats.min_price,
+			stats.max_price,
+			(SELECT COALESCE(json_agg(json_build_object('value', type, 'count', cnt) ORDER BY cnt DESC, type), '[]')
+			 FROM (SELECT type, COUNT(*) AS cnt FROM filtered GROUP BY type) t),
+			(SELECT COALESCE(json_agg(json_build_object('value', color, 'count', cnt) ORDER BY cnt DESC, color), '[]')
+			 FROM (SELECT color, COUNT(*) AS cnt FROM filtered WHERE color <> '' GROUP BY color) c),
+			(SELECT COALESCE(json_agg(json_build_object('bucket', bucket, 'count', cnt) ORDER BY bucket), '[]')
+			 FROM (
+				SELECT CASE
+					WHEN stats.max_price = stats.min_price THEN 1
+					-- width_bucket относит максимум к корзине n+1, он остается в последней
+					ELSE LEAST(width_bucket(price, stats.min_price, stats.max_price, $%[1]d), $%[1]d)
+				END AS bucket, COUNT(*) AS cnt
+				FROM filtered, stats
+				GROUP BY bucket
+			 ) b)
+		FROM stats
+	`, len(args)+1)
+	args = append(args, buckets)
+
+	var facets models.ProductFacets
+	var minPrice, maxPrice sql.NullFloat64
+	var typesJSON, colorsJSON, bucketsJSON []byte
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&facets.Total,
+		&minPrice,
+		&maxPrice,
+		&typesJSON,
+		&colorsJSON,
+		&bucketsJSON,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product facets: %w", err)
+	}
+
+	if err := json.Unmarshal(typesJSON, &facets.Types); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal type facets: %w", err)
+	}
+	if err := json.Unmarshal(colorsJSON, &facets.Colors); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal color facets: %w", err)
+	}
+
+	var counts []struct {
+		Bucket int `json:"bucket"`
+		Count  int `json:"count"`
+	}
+	if err := json.Unmarshal(bucketsJSON, &counts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal price buckets: %w", err)
+	}
+
+	facets.Types = nonNil(facets.Types)
+	facets.Colors = nonNil(facets.Colors)
+	facets.Price.Buckets = []models.PriceBucket{}
+	if facets.Total == 0 {
+		return &facets, nil
+	}
+
+	facets.Price.Min = minPrice.Float64
+	facets.Price.Max = maxPrice.Float64
+
+	// Одна цена на все товары - одна корзина нулевой ширины
+	n := buckets
+	if facets.Price.Min == facets.Price.Max {
+		n = 1
+	}
+	width := (facets.Price.Max - facets.Price.Min) / float64(n)
+	facets.Price.Buckets = make([]models.PriceBucket, n)
+	for i := range facets.Price.Buckets {
+		facets.Price.Buckets[i] = models.PriceBucket{
+			From: facets.Price.Min + float64(i)*width,
+			To:   facets.Price.Min + float64(i+1)*width,
+		}
+	}
+	facets.Price.Buckets[n-1].To = facets.Price.Max
+	for _, c := range counts {
+		if c.Bucket >= 1 && c.Bucket <= n {
+			facets.Price.Buckets[c.Bucket-1].Count = c.Count
+		}
+	}
+
+	return &facets, nil
+}
+
+func nonNil(counts []models.FacetCount) []models.FacetCount {
+	if counts == nil {
+		return []models.FacetCount{}
+	}
+	return counts
+}
@@ -5,13 +5,23 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/FollG/kafka-with-go/internal/domain/models"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// dbtx - общий интерфейс *sql.DB и *sql.Tx, чтобы одни и те же запросы
+// выполнялись как отдельно, так и внутри транзакции
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type ProductRepository struct {
 	db *sql.DB
 }
@@ -23,10 +33,16 @@ func NewProductRepository(db *sql.DB) *ProductRepository {
 }
 
 func (r *ProductRepository) Create(ctx context.Context, product *models.Product) error {
+	return createProduct(ctx, r.db, product)
+}
+
+func createProduct(ctx context.Context, q dbtx, product *models.Product) error {
+	// ID резервируется в API (NextID); нулевой ID у старых событий берется из последовательности
 	query := `
-		INSERT INTO products (name, weight, unit, color, type, price, attributes)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at, updated_at
+		INSERT INTO products (id, name, weight, unit, color, type, price, attributes)
+		VALUES (COALESCE(NULLIF($1, 0), nextval(pg_get_serial_sequence('products', 'id'))),
+			$2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, version, created_at, updated_at
 	`
 
 	attributesJSON, err := json.Marshal(product.Attributes)
@@ -34,7 +50,8 @@ func (r *ProductRepository) Create(ctx context.Context, product *models.Product)
 		return fmt.Errorf("failed to marshal attributes: %w", err)
 	}
 
-	err = r.db.QueryRowContext(ctx, query,
+	err = q.QueryRowContext(ctx, query,
+		product.ID,
 		product.Name,
 		product.Weight,
 		product.Unit,
@@ -42,7 +59,7 @@ func (r *ProductRepository) Create(ctx context.Context, product *models.Product)
 		product.Type,
 		product.Price,
 		attributesJSON,
-	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	).Scan(&product.ID, &product.Version, &product.CreatedAt, &product.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create product: %w", err)
@@ -51,17 +68,85 @@ func (r *ProductRepository) Create(ctx context.Context, product *models.Product)
 	return nil
 }
 
+// NextID резервирует ID для нового товара, чтобы API мог вернуть его клиенту
+// до того, как процессор вставит строку
+func (r *ProductRepository) NextID(ctx context.Context) (int, error) {
+	ids, err := reserveProductIDs(ctx, r.db, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int(ids[0]), nil
+}
+
+func (r *ProductRepository) NextIDs(ctx context.Context, n int) ([]int, error) {
+	reserved, err := reserveProductIDs(ctx, r.db, n)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(reserved))
+	for i, id := range reserved {
+		ids[i] = int(id)
+	}
+	return ids, nil
+}
+
 func (r *ProductRepository) GetByID(ctx context.Context, id int) (*models.Product, error) {
+	return getProduct(ctx, r.db, id, false)
+}
+
+// GetByIDs читает товары по списку ID; отсутствующие ID пропускаются,
+// порядок результата не определен
+func (r *ProductRepository) GetByIDs(ctx context.Context, ids []int) ([]*models.Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	productIDs := make([]int64, len(ids))
+	for i, id := range ids {
+		productIDs[i] = int64(id)
+	}
+
+	query := `SELECT ` + productSelectColumns + ` FROM products WHERE id = ANY($1::bigint[])`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	products := make([]*models.Product, 0, len(ids))
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return products, nil
+}
+
+// getProduct читает товар; forUpdate блокирует строку до конца транзакции
+func getProduct(ctx context.Context, q dbtx, id int, forUpdate bool) (*models.Product, error) {
 	query := `
-		SELECT id, name, weight, unit, color, type, price, attributes, created_at, updated_at
+		SELECT id, name, weight, unit, color, type, price, attributes, version, created_at, updated_at
 		FROM products
 		WHERE id = $1
 	`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
 
 	var product models.Product
 	var attributesJSON []byte
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := q.QueryRowContext(ctx, query, id).Scan(
 		&product.ID,
 		&product.Name,
 		&product.Weight,
@@ -70,6 +155,7 @@ func (r *ProductRepository) GetByID(ctx context.Context, id int) (*models.Produc
 		&product.Type,
 		&product.Price,
 		&attributesJSON,
+		&product.Version,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 	)
@@ -89,12 +175,18 @@ func (r *ProductRepository) GetByID(ctx context.Context, id int) (*models.Produc
 }
 
 func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
+	return updateProduct(ctx, r.db, product, 0)
+}
+
+// updateProduct обновляет товар, если его версия равна expectedVersion (0 - без проверки).
+// Версия и обе даты берутся из строки: процессор кладет product в кеш целиком.
+func updateProduct(ctx context.Context, q dbtx, product *models.Product, expectedVersion int64) error {
 	query := `
 		UPDATE products 
 		SET name = $1, weight = $2, unit = $3, color = $4, type = $5, 
-			price = $6, attributes = $7, updated_at = NOW()
-		WHERE id = $8
-		RETURNING updated_at
+			price = $6, attributes = $7, version = version + 1, updated_at = NOW()
+		WHERE id = $8 AND ($9::bigint = 0 OR version = $9)
+		RETURNING version, created_at, updated_at
 	`
 
 	attributesJSON, err := json.Marshal(product.Attributes)
@@ -102,7 +194,7 @@ func (r *ProductRepository) Update(ctx context.Context, product *models.Product)
 		return fmt.Errorf("failed to marshal attributes: %w", err)
 	}
 
-	err = r.db.QueryRowContext(ctx, query,
+	err = q.QueryRowContext(ctx, query,
 		product.Name,
 		product.Weight,
 		product.Unit,
@@ -111,11 +203,12 @@ func (r *ProductRepository) Update(ctx context.Context, product *models.Product)
 		product.Price,
 		attributesJSON,
 		product.ID,
-	).Scan(&product.UpdatedAt)
+		expectedVersion,
+	).Scan(&product.Version, &product.CreatedAt, &product.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.ErrProductNotFound
+			return missingOrConflict(ctx, q, product.ID)
 		}
 		return fmt.Errorf("failed to update product: %w", err)
 	}
@@ -124,49 +217,449 @@ func (r *ProductRepository) Update(ctx context.Context, product *models.Product)
 }
 
 func (r *ProductRepository) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM products WHERE id = $1`
+	_, err := deleteProduct(ctx, r.db, id, 0)
+	return err
+}
 
-	result, err := r.db.ExecContext(ctx, query, id)
+// deleteProduct удаляет товар, если его версия равна expectedVersion (0 - без проверки),
+// и возвращает версию удаленной строки
+func deleteProduct(ctx context.Context, q dbtx, id int, expectedVersion int64) (int64, error) {
+	query := `DELETE FROM products WHERE id = $1 AND ($2::bigint = 0 OR version = $2) RETURNING version`
+
+	var version int64
+	err := q.QueryRowContext(ctx, query, id, expectedVersion).Scan(&version)
 	if err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
+		if err == sql.ErrNoRows {
+			return 0, missingOrConflict(ctx, q, id)
+		}
+		return 0, fmt.Errorf("failed to delete product: %w", err)
+	}
+
+	return version, nil
+}
+
+// deletedProduct - карточка удаленного товара в событии: по ее версии кеш
+// отличает чтения, начатые до удаления
+func deletedProduct(id int, version int64) *models.Product {
+	return &models.Product{ID: id, Version: version}
+}
+
+// missingOrConflict объясняет, почему условное изменение не затронуло строку:
+// товара нет или его версия отличается от ожидаемой
+func missingOrConflict(ctx context.Context, q dbtx, id int) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check product existence: %w", err)
+	}
+	if exists {
+		return models.ErrVersionConflict
+	}
+	return models.ErrProductNotFound
+}
+
+// patchProduct применяет патч к заблокированной текущей строке и проверяет
+// результат через validate. Итоговый товар записывается в event.ProductData
+// для обновления кеша.
+func patchProduct(ctx context.Context, q dbtx, event *models.ProductEvent, validate func(*models.Product) error) error {
+	current, err := getProduct(ctx, q, event.ProductID, true)
+	if err != nil {
+		return err
+	}
+	if event.Version != 0 && current.Version != event.Version {
+		return models.ErrVersionConflict
+	}
+
+	patched, err := current.ApplyPatch(event.PatchFormat, event.Patch)
+	if err != nil {
+		return err
+	}
+	if validate != nil {
+		if err := validate(patched); err != nil {
+			return fmt.Errorf("%w: %v", models.ErrInvalidProduct, err)
+		}
+	}
+
+	if err := updateProduct(ctx, q, patched, current.Version); err != nil {
+		return err
+	}
+	event.ProductData = patched
+
+	return nil
+}
+
+// advanceSequence запоминает Sequence последнего примененного события товара.
+// Запись остается и после удаления товара, поэтому опоздавшее событие
+// не вернет старые данные и не воскресит удаленный товар.
+func advanceSequence(ctx context.Context, q dbtx, productID int, sequence int64) error {
+	if productID == 0 || sequence == 0 {
+		return nil
+	}
+
+	result, err := q.ExecContext(ctx, `
+		INSERT INTO product_sequences (product_id, last_sequence)
+		VALUES ($1, $2)
+		ON CONFLICT (product_id) DO UPDATE
+			SET last_sequence = EXCLUDED.last_sequence, updated_at = NOW()
+			WHERE product_sequences.last_sequence < EXCLUDED.last_sequence
+	`, productID, sequence)
+	if err != nil {
+		return fmt.Errorf("failed to advance product sequence: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
+	if rowsAffected == 0 {
+		return models.ErrStaleEvent
+	}
+
+	return nil
+}
 
+// ApplyEvent применяет событие из Kafka и отмечает его в processed_events
+// в одной транзакции. Повторная доставка того же события возвращает
+// models.ErrEventAlreadyProcessed, а событие старше уже примененного -
+// models.ErrStaleEvent; в обоих случаях ничего не меняется. validate проверяет
+// итог product_patched: он известен только внутри транзакции.
+func (r *ProductRepository) ApplyEvent(ctx context.Context, event *models.ProductEvent, validate func(*models.Product) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	// При конкурентной доставке второй INSERT дождется коммита первого
+	// и не вставит строку
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO processed_events (event_id, event_type)
+		VALUES ($1, $2)
+		ON CONFLICT (event_id) DO NOTHING
+	`, event.EventID, string(event.EventType))
+	if err != nil {
+		return fmt.Errorf("failed to record processed event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
 	if rowsAffected == 0 {
-		return models.ErrProductNotFound
+		return models.ErrEventAlreadyProcessed
+	}
+
+	if err := advanceSequence(ctx, tx, eventProductID(event), event.Sequence); err != nil {
+		return err
+	}
+
+	switch event.EventType {
+	case models.ProductCreated:
+		err = createProduct(ctx, tx, event.ProductData)
+	case models.ProductUpdated:
+		err = updateProduct(ctx, tx, event.ProductData, event.Version)
+	case models.ProductDeleted:
+		var version int64
+		if version, err = deleteProduct(ctx, tx, event.ProductID, event.Version); err == nil {
+			event.ProductData = deletedProduct(event.ProductID, version)
+		}
+	case models.ProductPatched:
+		err = patchProduct(ctx, tx, event, validate)
+	default:
+		err = fmt.Errorf("unknown event type: %s", event.EventType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
 func (r *ProductRepository) List(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error) {
+	conditions, args := filterConditions(filter)
 	query := `
-		SELECT id, name, weight, unit, color, type, price, attributes, created_at, updated_at
+		SELECT ` + productSelectColumns + `
 		FROM products
-		WHERE 1=1
-	`
+		WHERE 1=1` + conditions
+
+	// id в сортировке нужен для стабильного порядка товаров с одинаковым created_at
+	switch {
+	case filter.After != nil:
+		// Keyset: поиск по индексу (created_at, id) вместо пропуска Offset строк
+		query += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)+1, len(args)+2)
+		query += " ORDER BY created_at DESC, id DESC"
+		query += fmt.Sprintf(" LIMIT $%d", len(args)+3)
+		args = append(args, filter.After.CreatedAt, filter.After.ID, filter.Limit)
+	case filter.Before != nil:
+		// Ближайшие к курсору товары идут первыми; порядок разворачивается ниже
+		query += fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", len(args)+1, len(args)+2)
+		query += " ORDER BY created_at ASC, id ASC"
+		query += fmt.Sprintf(" LIMIT $%d", len(args)+3)
+		args = append(args, filter.Before.CreatedAt, filter.Before.ID, filter.Limit)
+	default:
+		query += orderBy(filter.Sort)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	// Выполняем запрос
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			panic(err)
+		}
+	}(rows)
+
+	var products []*models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if filter.Before != nil {
+		slices.Reverse(products)
+	}
+
+	return products, nil
+}
+
+// Count возвращает точное количество товаров по фильтру; Limit, Offset и
+// курсоры не учитываются
+func (r *ProductRepository) Count(ctx context.Context, filter models.ProductFilter) (int, error) {
+	conditions, args := filterConditions(filter)
+	query := `SELECT COUNT(*) FROM products WHERE 1=1` + conditions
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	return count, nil
+}
+
+// EstimateCount возвращает оценку количества товаров по статистике
+// планировщика: без фильтра - reltuples из pg_class, с фильтром - число строк
+// из плана EXPLAIN. Дешево на больших таблицах, но точность зависит от ANALYZE.
+func (r *ProductRepository) EstimateCount(ctx context.Context, filter models.ProductFilter) (int, error) {
+	conditions, args := filterConditions(filter)
+
+	if conditions == "" {
+		var reltuples float64
+		query := `SELECT reltuples FROM pg_class WHERE oid = 'products'::regclass`
+		if err := r.db.QueryRowContext(ctx, query).Scan(&reltuples); err != nil {
+			return 0, fmt.Errorf("failed to estimate products count: %w", err)
+		}
+		// -1 - таблица еще ни разу не анализировалась, тогда оценка берется из плана
+		if reltuples >= 0 {
+			return int(reltuples), nil
+		}
+	}
+
+	var plan []byte
+	query := `EXPLAIN (FORMAT JSON) SELECT 1 FROM products WHERE 1=1` + conditions
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&plan); err != nil {
+		return 0, fmt.Errorf("failed to explain products count: %w", err)
+	}
+
+	var explain []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explain); err != nil {
+		return 0, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	if len(explain) == 0 {
+		return 0, fmt.Errorf("empty query plan")
+	}
+
+	return int(explain[0].Plan.Rows), nil
+}
+
+// streamFetchSize - сколько строк Stream забирает из курсора за один FETCH
+const streamFetchSize = 500
+
+// Stream читает все товары по фильтру через серверный курсор порциями по
+// streamFetchSize и передает их в fn по одному, не держа выборку в памяти.
+// Limit и Offset фильтра не учитываются, товары идут по возрастанию ID.
+// Ошибка fn прерывает чтение и возвращается как есть.
+func (r *ProductRepository) Stream(ctx context.Context, filter models.ProductFilter, fn func(*models.Product) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	conditions, args := filterConditions(filter)
+	declare := `
+		DECLARE product_stream NO SCROLL CURSOR FOR
+		SELECT ` + productSelectColumns + `
+		FROM products
+		WHERE 1=1` + conditions + `
+		ORDER BY id`
+	if _, err := tx.ExecContext(ctx, declare, args...); err != nil {
+		return fmt.Errorf("failed to declare cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM product_stream", streamFetchSize)
+	for {
+		fetched, err := fetchProducts(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if fetched < streamFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+func fetchProducts(ctx context.Context, tx *sql.Tx, fetch string, fn func(*models.Product) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	fetched := 0
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return fetched, err
+		}
+		fetched++
+		if err := fn(product); err != nil {
+			return fetched, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fetched, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return fetched, nil
+}
+
+// productSelectColumns - колонки в порядке scanProduct
+const productSelectColumns = `id, name, weight, unit, color, type, price, attributes, version, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProduct(row rowScanner) (*models.Product, error) {
+	var product models.Product
+	var attributesJSON []byte
+
+	err := row.Scan(
+		&product.ID,
+		&product.Name,
+		&product.Weight,
+		&product.Unit,
+		&product.Color,
+		&product.Type,
+		&product.Price,
+		&attributesJSON,
+		&product.Version,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan product: %w", err)
+	}
+
+	if err := json.Unmarshal(attributesJSON, &product.Attributes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attributes: %w", err)
+	}
+
+	return &product, nil
+}
+
+// sortExpressions - SQL-выражения ключей сортировки. В запрос попадают только
+// они, значение параметра sort в SQL не подставляется.
+var sortExpressions = map[models.SortKey]string{
+	models.SortPrice:     "price",
+	models.SortName:      "name",
+	models.SortWeight:    "weight",
+	models.SortCreatedAt: "created_at",
+	models.SortUpdatedAt: "updated_at",
+
+	models.SortWarrantyMonths:     "(attributes->>'warranty_months')::int",
+	models.SortFootSize:           "(attributes->>'foot_size')::numeric",
+	models.SortHeadCircumference:  "(attributes->>'head_circumference')::numeric",
+	models.SortChestCircumference: "(attributes->>'chest_circumference')::numeric",
+	models.SortWaistCircumference: "(attributes->>'waist_circumference')::numeric",
+	models.SortHipCircumference:   "(attributes->>'hip_circumference')::numeric",
+}
+
+// orderBy строит ORDER BY по полям сортировки. id в конце дает стабильный
+// порядок при равных значениях; товары без атрибута идут последними.
+func orderBy(sort []models.SortField) string {
+	if len(sort) == 0 {
+		return " ORDER BY created_at DESC, id DESC"
+	}
+
+	terms := make([]string, 0, len(sort)+1)
+	for _, field := range sort {
+		expr, ok := sortExpressions[field.Key]
+		if !ok {
+			continue
+		}
+		if field.Desc {
+			expr += " DESC"
+		}
+		if strings.HasPrefix(string(field.Key), "attributes.") {
+			expr += " NULLS LAST"
+		}
+		terms = append(terms, expr)
+	}
+	terms = append(terms, "id DESC")
+
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// filterConditions переводит фильтр в условия " AND ..." и их аргументы;
+// плейсхолдеры нумеруются с $1
+func filterConditions(filter models.ProductFilter) (string, []interface{}) {
+	var conditions strings.Builder
 	args := []interface{}{}
 	argCounter := 1
 
 	// Добавляем условия фильтрации
 	if filter.MinPrice != nil {
-		query += fmt.Sprintf(" AND price >= $%d", argCounter)
+		conditions.WriteString(fmt.Sprintf(" AND price >= $%d", argCounter))
 		args = append(args, *filter.MinPrice)
 		argCounter++
 	}
 
 	if filter.MaxPrice != nil {
-		query += fmt.Sprintf(" AND price <= $%d", argCounter)
+		conditions.WriteString(fmt.Sprintf(" AND price <= $%d", argCounter))
 		args = append(args, *filter.MaxPrice)
 		argCounter++
 	}
 
 	if filter.Color != "" {
-		query += fmt.Sprintf(" AND color = $%d", argCounter)
+		conditions.WriteString(fmt.Sprintf(" AND color = $%d", argCounter))
 		args = append(args, filter.Color)
 		argCounter++
 	}
@@ -178,57 +671,85 @@ func (r *ProductRepository) List(ctx context.Context, filter models.ProductFilte
 			args = append(args, string(t))
 			argCounter++
 		}
-		query += fmt.Sprintf(" AND type IN (%s)", strings.Join(placeholders, ","))
+		conditions.WriteString(fmt.Sprintf(" AND type IN (%s)", strings.Join(placeholders, ",")))
 	}
 
-	query += " ORDER BY created_at DESC"
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argCounter, argCounter+1)
-	args = append(args, filter.Limit, filter.Offset)
-	argCounter += 2
+	// Точные значения атрибутов - одно условие containment, его обслуживает GIN-индекс
+	exact := map[string]string{}
+	if filter.Size != "" {
+		exact["size"] = filter.Size
+	}
+	if filter.Material != "" {
+		exact["material"] = filter.Material
+	}
+	if filter.Voltage != "" {
+		exact["voltage"] = filter.Voltage
+	}
+	if len(exact) > 0 {
+		contains, _ := json.Marshal(exact)
+		conditions.WriteString(fmt.Sprintf(" AND attributes @> $%d::jsonb", argCounter))
+		args = append(args, string(contains))
+		argCounter++
+	}
 
-	// Выполняем запрос
-	rows, err := r.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list products: %w", err)
+	// Диапазоны приводят атрибут к типу только после проверки значения: одна
+	// строка с некорректным атрибутом (импорт, старые данные) не проходит
+	// фильтр, а не роняет весь запрос
+	addRange := func(expr, op string, value interface{}) {
+		conditions.WriteString(fmt.Sprintf(" AND %s %s $%d", expr, op, argCounter))
+		args = append(args, value)
+		argCounter++
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(rows)
 
-	var products []*models.Product
-	for rows.Next() {
-		var product models.Product
-		var attributesJSON []byte
-
-		err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.Weight,
-			&product.Unit,
-			&product.Color,
-			&product.Type,
-			&product.Price,
-			&attributesJSON,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan product: %w", err)
-		}
+	if filter.MinWarrantyMonths != nil {
+		addRange(attributeAs("warranty_months", "int"), ">=", *filter.MinWarrantyMonths)
+	}
+	if filter.MaxWarrantyMonths != nil {
+		addRange(attributeAs("warranty_months", "int"), "<=", *filter.MaxWarrantyMonths)
+	}
+	if filter.MinFootSize != nil {
+		addRange(attributeAs("foot_size", "numeric"), ">=", *filter.MinFootSize)
+	}
+	if filter.MaxFootSize != nil {
+		addRange(attributeAs("foot_size", "numeric"), "<=", *filter.MaxFootSize)
+	}
+	if filter.ExpiresBefore != nil {
+		addRange(attributeAs("expiry_date", "timestamptz"), "<", *filter.ExpiresBefore)
+	}
+	if filter.ExpiresAfter != nil {
+		addRange(attributeAs("expiry_date", "timestamptz"), ">", *filter.ExpiresAfter)
+	}
 
-		if err := json.Unmarshal(attributesJSON, &product.Attributes); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal attributes: %w", err)
-		}
+	return conditions.String(), args
+}
+
+// attributeAs - SQL-выражение атрибута, приведенного к типу typ; значение,
+// которое не приводится к типу, дает NULL. key и typ - константы из кода.
+func attributeAs(key, typ string) string {
+	return fmt.Sprintf("CASE WHEN pg_input_is_valid(attributes->>'%[1]s', '%[2]s') THEN (attributes->>'%[1]s')::%[2]s END", key, typ)
+}
 
-		products = append(products, &product)
+// DeleteProcessedBefore удаляет до limit отметок processed_events старше before.
+// Удаление порциями не держит долгих блокировок на таблице, в которую пишет консьюмер.
+func (r *ProductRepository) DeleteProcessedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM processed_events
+		WHERE event_id IN (
+			SELECT event_id FROM processed_events
+			WHERE processed_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete processed events: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	return products, nil
+	return rowsAffected, nil
 }
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// ts_headline выделяет совпадения символами из области частного использования,
+// а не тегами: текст товара экранируется уже после выделения, и разметкой в
+// ответе могут быть только <mark> и </mark>
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// searchHeadlineOptions - параметры ts_headline для фрагмента с подсветкой
+const searchHeadlineOptions = `StartSel=` + highlightStart + `, StopSel=` + highlightStop + `, MaxWords=20, MinWords=5, MaxFragments=2`
+
+var highlightMarks = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// renderHighlight экранирует фрагмент как HTML и заменяет маркеры совпадений на <mark>
+func renderHighlight(headline string) string {
+	return highlightMarks.Replace(html.EscapeString(headline))
+}
+
+// Search ищет товары по search_vector (название, цвет, материал, пищевая
+// ценность) с префиксным совпадением слов и условиями фильтра. Результаты
+// упорядочены по релевантности; вторым значением возвращается общее число
+// найденных товаров. Sort и курсоры фильтра не учитываются.
+func (r *ProductRepository) Search(ctx context.Context, text string, filter models.ProductFilter) ([]*models.SearchResult, int, error) {
+	tsquery := prefixQuery(text)
+	if tsquery == "" {
+		return nil, 0, nil
+	}
+
+	conditions, args := filterConditions(filter)
+	n := len(args)
+	query := fmt.Sprintf(`
+		WITH q AS (SELECT to_tsquery('russian', $%d) AS query)
+		SELECT `+productSelectColumns+`,
+			ts_rank_cd(search_vector, q.query) AS rank,
+			ts_headline('russian',
+				translate(
+					concat_ws(' · ', name, NULLIF(color, ''), attributes->>'material', attributes->>'nutritional_info'),
+					'`+highlightStart+highlightStop+`', ''),
+				q.query, '`+searchHeadlineOptions+`'),
+			COUNT(*) OVER () AS total
+		FROM products, q
+		WHERE search_vector @@ q.query`+conditions+`
+		ORDER BY rank DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, n+1, n+2, n+3)
+	args = append(args, tsquery, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search products: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var results []*models.SearchResult
+	total := 0
+	for rows.Next() {
+		var result models.SearchResult
+		product, err := scanProduct(searchRow{rows, &result, &total})
+		if err != nil {
+			return nil, 0, err
+		}
+		result.Product = product
+		result.Highlight = renderHighlight(result.Highlight)
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	// Страница за пределами выдачи: строк нет, и COUNT(*) OVER () не посчитан
+	if len(results) == 0 && filter.Offset > 0 {
+		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM products WHERE search_vector @@ to_tsquery('russian', $%d)`+conditions, n+1)
+		if err := r.db.QueryRowContext(ctx, countQuery, args[:n+1]...).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+		}
+	}
+
+	return results, total, nil
+}
+
+// searchRow дочитывает после колонок товара релевантность, фрагмент и общее
+// число найденных, чтобы переиспользовать scanProduct
+type searchRow struct {
+	rows   *sql.Rows
+	result *models.SearchResult
+	total  *int
+}
+
+func (s searchRow) Scan(dest ...interface{}) error {
+	return s.rows.Scan(append(dest, &s.result.Rank, &s.result.Highlight, s.total)...)
+}
+
+// prefixQuery превращает строку поиска в tsquery, где каждое слово ищется
+// по префиксу: "красн футб" -> "красн:* & футб:*". Операторы и спецсимволы
+// tsquery отбрасываются, поэтому пользовательский ввод не ломает запрос.
+func prefixQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
@@ -2,8 +2,10 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"time"
 
 	"github.com/FollG/kafka-with-go/internal/domain/models"
@@ -11,20 +13,74 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// listGenerationKey - счетчик поколения кешированных списков и агрегатов
+const listGenerationKey = "products:list:generation"
+
 type ProductCache struct {
-	client *redis.Client
-	ttl    time.Duration
+	client      redis.UniversalClient
+	ttl         time.Duration // мягкий TTL карточки: после него запись устаревшая
+	staleTTL    time.Duration // сколько еще отдавать устаревшую карточку, пока она обновляется
+	negativeTTL time.Duration // TTL отрицательной записи; 0 - не кешировать отсутствие
+	codec       *ValueCodec
 }
 
-func NewProductCache(client *redis.Client, ttl time.Duration) *ProductCache {
+func NewProductCache(client redis.UniversalClient, ttl time.Duration) *ProductCache {
 	return &ProductCache{
 		client: client,
 		ttl:    ttl,
+		codec:  &ValueCodec{id: codecIDs["json"], codec: JSONCodec{}},
+	}
+}
+
+// SetCodec задает кодек новых записей; записи других кодеков по-прежнему читаются
+func (c *ProductCache) SetCodec(codec *ValueCodec) {
+	c.codec = codec
+}
+
+// SetStaleTTL включает stale-while-revalidate: карточка хранится в Redis
+// ttl+staleTTL, а после ttl Get помечает ее устаревшей
+func (c *ProductCache) SetStaleTTL(staleTTL time.Duration) {
+	c.staleTTL = staleTTL
+}
+
+// SetNegativeTTL включает кеширование отсутствующих товаров
+func (c *ProductCache) SetNegativeTTL(negativeTTL time.Duration) {
+	c.negativeTTL = negativeTTL
+}
+
+// productEntry - формат карточки в Redis. SoftExpiresAt - момент (Unix мс),
+// после которого карточка считается устаревшей. Version есть только у
+// отрицательной записи удаленного товара (надгробия) - это версия
+// удаленной строки.
+type productEntry struct {
+	Product       *models.Product `json:"p,omitempty"`
+	NotFound      bool            `json:"nf,omitempty"`
+	Version       int64           `json:"v,omitempty"`
+	SoftExpiresAt int64           `json:"se,omitempty"`
+}
+
+// replaceableBy - можно ли заменить запись карточкой версии version.
+// Карточку заменяет версия не ниже, надгробие - только более новая версия,
+// отрицательную запись без версии и нечитаемую запись - любая.
+func (e *productEntry) replaceableBy(version int64) bool {
+	switch {
+	case e == nil:
+		return true
+	case e.NotFound:
+		return e.Version < version
+	case e.Product == nil:
+		return true
+	default:
+		return e.Product.Version <= version
 	}
 }
 
-func (c *ProductCache) Get(ctx context.Context, key string) (*models.Product, error) {
-	data, err := c.client.Get(ctx, key).Result()
+// earlyRefreshShare - доля ttl, в пределах которой до мягкого истечения
+// запись может быть обновлена заранее
+const earlyRefreshShare = 0.1
+
+func (c *ProductCache) Get(ctx context.Context, key string) (*models.CachedProduct, error) {
+	data, err := c.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Ключ не найден - это не ошибка
@@ -32,21 +88,72 @@ func (c *ProductCache) Get(ctx context.Context, key string) (*models.Product, er
 		return nil, fmt.Errorf("failed to get from cache: %w", err)
 	}
 
-	var product models.Product
-	if err := json.Unmarshal([]byte(data), &product); err != nil {
+	var entry productEntry
+	if err := c.codec.Unmarshal(data, &entry); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal product: %w", err)
 	}
+	if entry.NotFound {
+		return &models.CachedProduct{NotFound: true}, nil
+	}
+	if entry.Product == nil {
+		return nil, nil // Запись в неизвестном формате считается промахом
+	}
+
+	return &models.CachedProduct{
+		Product: entry.Product,
+		Stale:   c.shouldRefresh(entry.SoftExpiresAt),
+	}, nil
+}
 
-	return &product, nil
+// shouldRefresh - вероятностное раннее обновление (XFetch): чем ближе мягкое
+// истечение, тем вероятнее, что очередной запрос обновит запись заранее.
+// Так обновления горячих ключей размазываются по времени, а не совпадают.
+func (c *ProductCache) shouldRefresh(softExpiresAt int64) bool {
+	if softExpiresAt == 0 {
+		return false
+	}
+	early := time.Duration(float64(c.ttl) * earlyRefreshShare * -math.Log(1-rand.Float64()))
+	return time.Now().Add(early).UnixMilli() >= softExpiresAt
 }
 
+// setAttempts - сколько раз Set повторяет запись, если ключ изменился между
+// чтением версии и записью
+const setAttempts = 3
+
+// Set записывает карточку, если в кеше нет более новой версии товара или
+// надгробия: чтение из БД, начатое до изменения или удаления, не перезапишет
+// то, что уже положил процессор. Версия сверяется в транзакции WATCH/MULTI
+// по одному ключу.
 func (c *ProductCache) Set(ctx context.Context, key string, product *models.Product) error {
-	data, err := json.Marshal(product)
+	data, err := c.codec.Marshal(productEntry{
+		Product:       product,
+		SoftExpiresAt: time.Now().Add(c.ttl).UnixMilli(),
+	})
 	if err != nil {
 		return fmt.Errorf("failed to marshal product: %w", err)
 	}
 
-	err = c.client.Set(ctx, key, data, c.ttl).Err()
+	write := func(tx *redis.Tx) error {
+		cached, err := c.cachedEntry(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if !cached.replaceableBy(product.Version) {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, c.ttl+c.staleTTL)
+			return nil
+		})
+		return err
+	}
+
+	for range setAttempts {
+		err = c.client.Watch(ctx, write, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to set cache: %w", err)
 	}
@@ -54,6 +161,86 @@ func (c *ProductCache) Set(ctx context.Context, key string, product *models.Prod
 	return nil
 }
 
+// cachedEntry - запись ключа в кеше; nil, если ключа нет или запись нечитаема
+func (c *ProductCache) cachedEntry(ctx context.Context, tx *redis.Tx, key string) (*productEntry, error) {
+	data, err := tx.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	var entry productEntry
+	if err := c.codec.Unmarshal(data, &entry); err != nil {
+		return nil, nil // Нечитаемую запись можно перезаписать
+	}
+	return &entry, nil
+}
+
+// SetMany записывает карточки одним pipeline и только в пустые ключи (SET NX):
+// прогрев читает снимок БД, и карточку, которую за это время обновил процессор
+// или положило чтение, он не перезапишет
+func (c *ProductCache) SetMany(ctx context.Context, products map[string]*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	softExpiresAt := time.Now().Add(c.ttl).UnixMilli()
+	pipe := c.client.Pipeline()
+	for key, product := range products {
+		data, err := c.codec.Marshal(productEntry{Product: product, SoftExpiresAt: softExpiresAt})
+		if err != nil {
+			return fmt.Errorf("failed to marshal product: %w", err)
+		}
+		pipe.SetNX(ctx, key, data, c.ttl+c.staleTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set cache batch: %w", err)
+	}
+
+	return nil
+}
+
+// SetNotFound запоминает, что товара нет, на negativeTTL. Запись делается
+// только при пустом ключе (SET NX): если товар успел появиться, пока шло
+// чтение из БД, его карточка не заменяется отрицательной записью.
+func (c *ProductCache) SetNotFound(ctx context.Context, key string) error {
+	if c.negativeTTL <= 0 {
+		return nil
+	}
+
+	data, err := c.codec.Marshal(productEntry{NotFound: true})
+	if err != nil {
+		return fmt.Errorf("failed to marshal negative entry: %w", err)
+	}
+
+	err = c.client.SetNX(ctx, key, data, c.negativeTTL).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set negative cache: %w", err)
+	}
+
+	return nil
+}
+
+// SetDeleted заменяет карточку надгробием с версией удаленной строки. Надгробие
+// читается как отсутствие товара и живет столько же, сколько карточка, поэтому
+// чтение из БД, начатое до удаления, не вернет товар в кеш через Set.
+func (c *ProductCache) SetDeleted(ctx context.Context, key string, version int64) error {
+	data, err := c.codec.Marshal(productEntry{NotFound: true, Version: version})
+	if err != nil {
+		return fmt.Errorf("failed to marshal tombstone: %w", err)
+	}
+
+	err = c.client.Set(ctx, key, data, c.ttl+c.staleTTL).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set tombstone: %w", err)
+	}
+
+	return nil
+}
+
 func (c *ProductCache) Delete(ctx context.Context, key string) error {
 	err := c.client.Del(ctx, key).Err()
 	if err != nil {
@@ -63,7 +250,7 @@ func (c *ProductCache) Delete(ctx context.Context, key string) error {
 }
 
 func (c *ProductCache) SetList(ctx context.Context, key string, products []*models.Product) error {
-	data, err := json.Marshal(products)
+	data, err := c.codec.Marshal(products)
 	if err != nil {
 		return fmt.Errorf("failed to marshal products list: %w", err)
 	}
@@ -77,7 +264,7 @@ func (c *ProductCache) SetList(ctx context.Context, key string, products []*mode
 }
 
 func (c *ProductCache) GetList(ctx context.Context, key string) ([]*models.Product, error) {
-	data, err := c.client.Get(ctx, key).Result()
+	data, err := c.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Ключ не найден - это не ошибка
@@ -86,9 +273,78 @@ func (c *ProductCache) GetList(ctx context.Context, key string) ([]*models.Produ
 	}
 
 	var products []*models.Product
-	if err := json.Unmarshal([]byte(data), &products); err != nil {
+	if err := c.codec.Unmarshal(data, &products); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal products list: %w", err)
 	}
 
 	return products, nil
 }
+
+func (c *ProductCache) SetFacets(ctx context.Context, key string, facets *models.ProductFacets) error {
+	data, err := c.codec.Marshal(facets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal facets: %w", err)
+	}
+
+	err = c.client.Set(ctx, key, data, c.ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set facets cache: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ProductCache) GetFacets(ctx context.Context, key string) (*models.ProductFacets, error) {
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil // Ключ не найден - это не ошибка
+		}
+		return nil, fmt.Errorf("failed to get facets from cache: %w", err)
+	}
+
+	var facets models.ProductFacets
+	if err := c.codec.Unmarshal(data, &facets); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal facets: %w", err)
+	}
+
+	return &facets, nil
+}
+
+func (c *ProductCache) SetCount(ctx context.Context, key string, count int) error {
+	if err := c.client.Set(ctx, key, count, c.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set count cache: %w", err)
+	}
+	return nil
+}
+
+func (c *ProductCache) GetCount(ctx context.Context, key string) (int, bool, error) {
+	count, err := c.client.Get(ctx, key).Int()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, false, nil // Ключ не найден - это не ошибка
+		}
+		return 0, false, fmt.Errorf("failed to get count from cache: %w", err)
+	}
+	return count, true, nil
+}
+
+func (c *ProductCache) ListGeneration(ctx context.Context) (int64, error) {
+	generation, err := c.client.Get(ctx, listGenerationKey).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil // Списки еще ни разу не сбрасывались
+		}
+		return 0, fmt.Errorf("failed to get list generation: %w", err)
+	}
+	return generation, nil
+}
+
+// InvalidateLists увеличивает поколение; записи прошлых поколений больше не
+// читаются и удаляются по TTL
+func (c *ProductCache) InvalidateLists(ctx context.Context) error {
+	if err := c.client.Incr(ctx, listGenerationKey).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate lists: %w", err)
+	}
+	return nil
+}
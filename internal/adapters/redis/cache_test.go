@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestProductEntryReplaceableBy(t *testing.T) {
+	card := func(version int64) *productEntry {
+		return &productEntry{Product: &models.Product{ID: 1, Version: version}}
+	}
+
+	tests := []struct {
+		name    string
+		entry   *productEntry
+		version int64
+		want    bool
+	}{
+		{"empty key", nil, 1, true},
+		{"older card", card(2), 3, true},
+		{"same card", card(3), 3, true},
+		{"newer card", card(4), 3, false},
+		{"negative entry without version", &productEntry{NotFound: true}, 1, true},
+		{"tombstone of the same version", &productEntry{NotFound: true, Version: 3}, 3, false},
+		{"tombstone of a newer version", &productEntry{NotFound: true, Version: 4}, 3, false},
+		{"tombstone of an older version", &productEntry{NotFound: true, Version: 2}, 3, true},
+		{"entry of unknown format", &productEntry{}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.replaceableBy(tt.version); got != tt.want {
+				t.Fatalf("replaceableBy(%d) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+// Чтение загрузило карточку до удаления, процессор удалил товар и записал
+// надгробие, затем чтение вызывает Set: товар не должен вернуться в кеш.
+// Нужен Redis по адресу REDIS_TEST_ADDR.
+func TestProductCacheSetAfterDelete(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer func() {
+		_ = client.Close()
+	}()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Fatalf("redis is unavailable: %v", err)
+	}
+
+	cache := NewProductCache(client, time.Minute)
+	key := "test:product:set-after-delete"
+	defer client.Del(ctx, key)
+
+	// Чтение из БД до удаления
+	loaded := &models.Product{ID: 1, Name: "old", Version: 3}
+
+	if err := cache.SetDeleted(ctx, key, 3); err != nil {
+		t.Fatalf("SetDeleted: %v", err)
+	}
+	if err := cache.Set(ctx, key, loaded); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, err := cache.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got == nil || !got.NotFound {
+		t.Fatalf("Get = %+v, want not found", got)
+	}
+}
@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec сериализует значения кеша
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec - MessagePack с именами полей из json-тегов, чтобы модели
+// не нуждались в отдельных тегах
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// zstd-кодировщик и декодировщик потокобезопасны для EncodeAll/DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// ZstdCodec сжимает результат codec алгоритмом zstd
+type ZstdCodec struct {
+	Codec Codec
+}
+
+func (c ZstdCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+func (c ZstdCodec) Unmarshal(data []byte, v interface{}) error {
+	raw, err := zstdDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decompress zstd: %w", err)
+	}
+	return c.Codec.Unmarshal(raw, v)
+}
+
+// SnappyCodec сжимает результат codec алгоритмом snappy: слабее zstd, но быстрее
+type SnappyCodec struct {
+	Codec Codec
+}
+
+func (c SnappyCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+func (c SnappyCodec) Unmarshal(data []byte, v interface{}) error {
+	raw, err := snappy.Decode(nil, data)
+	if err != nil {
+		return fmt.Errorf("failed to decompress snappy: %w", err)
+	}
+	return c.Codec.Unmarshal(raw, v)
+}
+
+// Значение в Redis начинается с заголовка: valueMagic, версия формата
+// заголовка и ID кодека. Читаются значения любого известного кодека, поэтому
+// кодек можно сменить без очистки кеша: старые записи дочитываются и
+// вытесняются по TTL. Значения без заголовка - JSON прежних версий.
+const (
+	valueMagic         byte = 0xCA // не может быть первым байтом JSON
+	valueFormatVersion byte = 1
+	valueHeaderSize         = 3
+)
+
+// codecIDs - ID кодеков в заголовке; ID нельзя переиспользовать
+var codecIDs = map[string]byte{
+	"json":           1,
+	"msgpack":        2,
+	"json+zstd":      3,
+	"msgpack+zstd":   4,
+	"json+snappy":    5,
+	"msgpack+snappy": 6,
+}
+
+var codecsByID = map[byte]Codec{
+	1: JSONCodec{},
+	2: MsgpackCodec{},
+	3: ZstdCodec{JSONCodec{}},
+	4: ZstdCodec{MsgpackCodec{}},
+	5: SnappyCodec{JSONCodec{}},
+	6: SnappyCodec{MsgpackCodec{}},
+}
+
+var errUnknownCodec = errors.New("unknown cache value codec")
+
+// ValueCodec пишет значения выбранным кодеком с заголовком и читает значения
+// любого известного кодека
+type ValueCodec struct {
+	id    byte
+	codec Codec
+}
+
+// NewValueCodec выбирает кодек по формату (json, msgpack) и сжатию (none, zstd, snappy)
+func NewValueCodec(format, compression string) (*ValueCodec, error) {
+	name := format
+	if compression != "" && compression != "none" {
+		name += "+" + compression
+	}
+
+	id, ok := codecIDs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: format %q, compression %q", errUnknownCodec, format, compression)
+	}
+
+	return &ValueCodec{id: id, codec: codecsByID[id]}, nil
+}
+
+func (c *ValueCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	value := make([]byte, 0, valueHeaderSize+len(data))
+	value = append(value, valueMagic, valueFormatVersion, c.id)
+	return append(value, data...), nil
+}
+
+func (c *ValueCodec) Unmarshal(value []byte, v interface{}) error {
+	if len(value) == 0 || value[0] != valueMagic {
+		return json.Unmarshal(value, v)
+	}
+	if len(value) < valueHeaderSize || value[1] != valueFormatVersion {
+		return fmt.Errorf("%w: unsupported header", errUnknownCodec)
+	}
+
+	codec, ok := codecsByID[value[2]]
+	if !ok {
+		return fmt.Errorf("%w: id %d", errUnknownCodec, value[2])
+	}
+	return codec.Unmarshal(value[valueHeaderSize:], v)
+}
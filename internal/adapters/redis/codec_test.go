@@ -0,0 +1,184 @@
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+func testEntry() productEntry {
+	head := 56.5
+	return productEntry{
+		Product: &models.Product{
+			ID:         7,
+			Name:       "Шапка",
+			Weight:     0.2,
+			Unit:       "piece",
+			Color:      "red",
+			Type:       models.ClothingHeadwear,
+			Price:      1499.9,
+			Attributes: models.Attributes{Size: "M", HeadCircumference: &head},
+			Version:    3,
+			CreatedAt:  time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC),
+			UpdatedAt:  time.Date(2026, 2, 3, 4, 5, 6, 700, time.UTC),
+		},
+		SoftExpiresAt: 1767225600000,
+	}
+}
+
+// assertEntry сравнивает записи; время сравнивается через Equal, так как
+// кодеки могут вернуть другую локацию
+func assertEntry(t *testing.T, got, want productEntry) {
+	t.Helper()
+
+	if got.Product == nil {
+		t.Fatalf("product lost: %+v", got)
+	}
+	if !got.Product.CreatedAt.Equal(want.Product.CreatedAt) || !got.Product.UpdatedAt.Equal(want.Product.UpdatedAt) {
+		t.Fatalf("times = %v/%v, want %v/%v", got.Product.CreatedAt, got.Product.UpdatedAt,
+			want.Product.CreatedAt, want.Product.UpdatedAt)
+	}
+
+	gotProduct, wantProduct := *got.Product, *want.Product
+	gotProduct.CreatedAt, gotProduct.UpdatedAt = time.Time{}, time.Time{}
+	wantProduct.CreatedAt, wantProduct.UpdatedAt = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(gotProduct, wantProduct) {
+		t.Fatalf("product = %+v, want %+v", gotProduct, wantProduct)
+	}
+	if got.NotFound != want.NotFound || got.SoftExpiresAt != want.SoftExpiresAt {
+		t.Fatalf("entry = %+v, want %+v", got, want)
+	}
+}
+
+func TestValueCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		format      string
+		compression string
+		id          byte
+	}{
+		{"json", "", 1},
+		{"json", "none", 1},
+		{"msgpack", "none", 2},
+		{"json", "zstd", 3},
+		{"msgpack", "zstd", 4},
+		{"json", "snappy", 5},
+		{"msgpack", "snappy", 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+"+"+tt.compression, func(t *testing.T) {
+			codec, err := NewValueCodec(tt.format, tt.compression)
+			if err != nil {
+				t.Fatalf("NewValueCodec: %v", err)
+			}
+
+			want := testEntry()
+			data, err := codec.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if len(data) < valueHeaderSize || data[0] != valueMagic || data[1] != valueFormatVersion || data[2] != tt.id {
+				t.Fatalf("header = % x, want %x %x %x", data[:min(len(data), valueHeaderSize)], valueMagic, valueFormatVersion, tt.id)
+			}
+
+			var got productEntry
+			if err := codec.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			assertEntry(t, got, want)
+
+			var negative productEntry
+			data, err = codec.Marshal(productEntry{NotFound: true})
+			if err != nil {
+				t.Fatalf("Marshal negative: %v", err)
+			}
+			if err := codec.Unmarshal(data, &negative); err != nil {
+				t.Fatalf("Unmarshal negative: %v", err)
+			}
+			if !negative.NotFound || negative.Product != nil {
+				t.Fatalf("negative entry = %+v", negative)
+			}
+		})
+	}
+}
+
+// Запись любого кодека читается кодеком с другими настройками: кодек
+// выбирается по заголовку
+func TestValueCodecReadsOtherCodecs(t *testing.T) {
+	reader, err := NewValueCodec("json", "none")
+	if err != nil {
+		t.Fatalf("NewValueCodec: %v", err)
+	}
+
+	for name, id := range codecIDs {
+		t.Run(name, func(t *testing.T) {
+			writer := &ValueCodec{id: id, codec: codecsByID[id]}
+
+			want := testEntry()
+			data, err := writer.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			var got productEntry
+			if err := reader.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			assertEntry(t, got, want)
+		})
+	}
+}
+
+func TestValueCodecReadsLegacyJSON(t *testing.T) {
+	codec, err := NewValueCodec("msgpack", "zstd")
+	if err != nil {
+		t.Fatalf("NewValueCodec: %v", err)
+	}
+
+	want := testEntry()
+	data, err := JSONCodec{}.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got productEntry
+	if err := codec.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	assertEntry(t, got, want)
+}
+
+func TestValueCodecErrors(t *testing.T) {
+	if _, err := NewValueCodec("xml", "none"); !errors.Is(err, errUnknownCodec) {
+		t.Fatalf("NewValueCodec(xml) error = %v, want %v", err, errUnknownCodec)
+	}
+	if _, err := NewValueCodec("json", "gzip"); !errors.Is(err, errUnknownCodec) {
+		t.Fatalf("NewValueCodec(gzip) error = %v, want %v", err, errUnknownCodec)
+	}
+
+	codec, err := NewValueCodec("json", "none")
+	if err != nil {
+		t.Fatalf("NewValueCodec: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{"short header", []byte{valueMagic, valueFormatVersion}},
+		{"unknown header version", []byte{valueMagic, valueFormatVersion + 1, 1, '{', '}'}},
+		{"unknown codec id", []byte{valueMagic, valueFormatVersion, 99, '{', '}'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entry productEntry
+			if err := codec.Unmarshal(tt.value, &entry); !errors.Is(err, errUnknownCodec) {
+				t.Fatalf("error = %v, want %v", err, errUnknownCodec)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Invalidations рассылает через pub/sub ключи карточек, которые реплики API
+// должны убрать из локального кеша. Доставка не гарантирована: сообщения,
+// отправленные во время переподключения подписчика, теряются, поэтому
+// локальный кеш все равно держит записи не дольше своего TTL.
+type Invalidations struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+func NewInvalidations(client redis.UniversalClient, channel string) *Invalidations {
+	return &Invalidations{
+		client:  client,
+		channel: channel,
+	}
+}
+
+// PublishInvalidation отправляет ключи одним сообщением
+func (i *Invalidations) PublishInvalidation(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := i.client.Publish(ctx, i.channel, strings.Join(keys, "\n")).Err(); err != nil {
+		return fmt.Errorf("failed to publish cache invalidation: %w", err)
+	}
+
+	return nil
+}
+
+// Задержки между попытками подписаться на канал инвалидаций
+const (
+	subscribeMinBackoff = time.Second
+	subscribeMaxBackoff = 30 * time.Second
+)
+
+// Subscribe передает в fn ключи из каждого сообщения канала до отмены ctx.
+// Обрывы соединения go-redis переживает сам; если же подписаться не удалось
+// (например, Redis недоступен при старте) или канал закрылся, подписка
+// повторяется с растущей задержкой.
+func (i *Invalidations) Subscribe(ctx context.Context, fn func(keys []string)) {
+	delay := subscribeMinBackoff
+	for {
+		err := i.subscribe(ctx, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			// Подписка работала и закрылась: начинаем с короткой задержки
+			delay = subscribeMinBackoff
+			err = fmt.Errorf("subscription to %s closed", i.channel)
+		}
+		fmt.Printf("Cache invalidation subscription failed, retrying in %s: %v\n", delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = min(delay*2, subscribeMaxBackoff)
+	}
+}
+
+// subscribe держит одну подписку; nil означает, что она была установлена
+func (i *Invalidations) subscribe(ctx context.Context, fn func(keys []string)) error {
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	defer func(pubsub *redis.PubSub) {
+		_ = pubsub.Close()
+	}(pubsub)
+
+	// Дожидаемся подтверждения подписки, чтобы сразу увидеть ошибку соединения
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", i.channel, err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			fn(strings.Split(msg.Payload, "\n"))
+		}
+	}
+}
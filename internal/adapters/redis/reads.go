@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// readsKey - sorted set: товар -> число прочтений
+	readsKey = "products:reads"
+	// maxTrackedReads - сколько самых читаемых товаров хранится в readsKey
+	maxTrackedReads = 100000
+)
+
+// ReadCounter копит прочтения карточек в памяти и раз в interval переносит
+// их в общий для всех реплик sorted set, чтобы не ходить в Redis на каждое чтение
+type ReadCounter struct {
+	client redis.UniversalClient
+
+	mu     sync.Mutex
+	counts map[int]int64
+}
+
+func NewReadCounter(client redis.UniversalClient) *ReadCounter {
+	return &ReadCounter{
+		client: client,
+		counts: make(map[int]int64),
+	}
+}
+
+func (c *ReadCounter) TrackRead(id int) {
+	c.mu.Lock()
+	c.counts[id]++
+	c.mu.Unlock()
+}
+
+// Run сбрасывает накопленные прочтения раз в interval до отмены ctx
+func (c *ReadCounter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Последний сброс, чтобы не потерять прочтения при остановке
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+			if err := c.flush(flushCtx); err != nil {
+				fmt.Printf("Failed to flush product reads: %v\n", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := c.flush(ctx); err != nil {
+				fmt.Printf("Failed to flush product reads: %v\n", err)
+			}
+		}
+	}
+}
+
+func (c *ReadCounter) flush(ctx context.Context) error {
+	c.mu.Lock()
+	counts := c.counts
+	c.counts = make(map[int]int64, len(counts))
+	c.mu.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	pipe := c.client.Pipeline()
+	for id, n := range counts {
+		pipe.ZIncrBy(ctx, readsKey, float64(n), strconv.Itoa(id))
+	}
+	// Редко читаемые товары вытесняются, чтобы множество не росло без предела
+	pipe.ZRemRangeByRank(ctx, readsKey, 0, -maxTrackedReads-1)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to flush reads: %w", err)
+	}
+
+	return nil
+}
+
+// MostRead возвращает ID n самых читаемых товаров по убыванию числа прочтений
+func (c *ReadCounter) MostRead(ctx context.Context, n int) ([]int, error) {
+	members, err := c.client.ZRevRange(ctx, readsKey, 0, int64(n)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get most read products: %w", err)
+	}
+
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
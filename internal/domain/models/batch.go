@@ -0,0 +1,25 @@
+package models
+
+// BatchAction - действие элемента пакетного запроса
+type BatchAction string
+
+const (
+	BatchCreate BatchAction = "create"
+	BatchUpdate BatchAction = "update"
+	BatchDelete BatchAction = "delete"
+)
+
+// BatchItem - одно изменение пакетного запроса
+type BatchItem struct {
+	Action    BatchAction
+	ProductID int      // для update и delete
+	Product   *Product // для create и update
+	Version   int64    // ожидаемая версия товара, 0 - без проверки
+}
+
+// BatchItemResult - итог приема элемента: операция или причина отказа
+type BatchItemResult struct {
+	ProductID int
+	Operation *Operation
+	Err       error
+}
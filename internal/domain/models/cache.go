@@ -0,0 +1,8 @@
+package models
+
+// CachedProduct - запись кеша карточки товара
+type CachedProduct struct {
+	Product  *Product
+	NotFound bool // отрицательная запись: товара нет в БД
+	Stale    bool // запись пора обновить в фоне, но ее еще можно отдавать
+}
@@ -5,4 +5,11 @@ import "errors"
 var (
 	ErrProductNotFound = errors.New("product not found")
 	ErrInvalidProduct  = errors.New("invalid product data")
+	ErrInvalidPatch    = errors.New("invalid patch document")
+	ErrInvalidSort     = errors.New("invalid sort")
+
+	ErrEventAlreadyProcessed = errors.New("event already processed")
+	ErrOperationNotFound     = errors.New("operation not found")
+	ErrVersionConflict       = errors.New("product version conflict")
+	ErrStaleEvent            = errors.New("event is older than the last applied change")
 )
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type EventType string
 
@@ -8,6 +12,7 @@ const (
 	ProductCreated EventType = "product_created"
 	ProductUpdated EventType = "product_updated"
 	ProductDeleted EventType = "product_deleted"
+	ProductPatched EventType = "product_patched" // частичное изменение, применяется к текущей строке
 )
 
 type ProductEvent struct {
@@ -16,7 +21,16 @@ type ProductEvent struct {
 	Timestamp   time.Time `json:"timestamp"`
 	ProductID   int       `json:"product_id,omitempty"`
 	ProductData *Product  `json:"product_data,omitempty"`
+	Version     int64     `json:"version,omitempty"` // ожидаемая версия товара (If-Match), 0 - без проверки
+
+	Patch       json.RawMessage `json:"patch,omitempty"` // документ изменения для product_patched
+	PatchFormat PatchFormat     `json:"patch_format,omitempty"`
 
 	ProducerID string `json:"producer_id"`
-	Sequence   int64  `json:"sequence"`
+	Sequence   int64  `json:"sequence"` // порядок изменений товара (id строки outbox), 0 - без проверки порядка
+}
+
+// Key возвращает ключ сообщения Kafka, все события одного товара попадают в одну партицию
+func (e *ProductEvent) Key() string {
+	return fmt.Sprintf("product-%d", e.ProductID)
 }
@@ -0,0 +1,29 @@
+package models
+
+// ProductFacets - агрегаты каталога по фильтру для витрины
+type ProductFacets struct {
+	Total  int          `json:"total"`
+	Types  []FacetCount `json:"types"`  // по убыванию количества
+	Colors []FacetCount `json:"colors"` // по убыванию количества; товары без цвета не учитываются
+	Price  PriceStats   `json:"price"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PriceStats - диапазон цен и гистограмма из равных по ширине корзин.
+// Min и Max равны нулю, если под фильтр не попал ни один товар.
+type PriceStats struct {
+	Min     float64       `json:"min"`
+	Max     float64       `json:"max"`
+	Buckets []PriceBucket `json:"buckets"`
+}
+
+// PriceBucket - корзина [From, To); последняя корзина включает To
+type PriceBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
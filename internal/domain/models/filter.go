@@ -1,10 +1,107 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type ProductFilter struct {
 	Limit    int
 	Offset   int
+	After    *ProductCursor // keyset-пагинация вперед; если задан, Offset не учитывается
+	Before   *ProductCursor // keyset-пагинация назад: товары перед курсором
+	Sort     []SortField    // пусто - по created_at DESC; с курсорами не сочетается
 	MinPrice *float64
 	MaxPrice *float64
 	Color    string
 	Types    []ProductType
+
+	// Фильтры по атрибутам; пустые строки и nil не ограничивают выборку
+	Size              string
+	Material          string
+	Voltage           string
+	MinWarrantyMonths *int
+	MaxWarrantyMonths *int
+	MinFootSize       *float64
+	MaxFootSize       *float64
+	ExpiresBefore     *time.Time
+	ExpiresAfter      *time.Time
+}
+
+// ProductCursor - позиция последнего товара страницы в порядке
+// (created_at DESC, id DESC); соседняя страница начинается строго после
+// (After) или строго перед (Before) ней
+type ProductCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// CursorOf возвращает позицию товара для следующей страницы
+func CursorOf(product *Product) ProductCursor {
+	return ProductCursor{CreatedAt: product.CreatedAt, ID: product.ID}
+}
+
+// SortKey - поле, по которому можно сортировать список товаров
+type SortKey string
+
+const (
+	SortPrice     SortKey = "price"
+	SortName      SortKey = "name"
+	SortWeight    SortKey = "weight"
+	SortCreatedAt SortKey = "created_at"
+	SortUpdatedAt SortKey = "updated_at"
+
+	SortWarrantyMonths     SortKey = "attributes.warranty_months"
+	SortFootSize           SortKey = "attributes.foot_size"
+	SortHeadCircumference  SortKey = "attributes.head_circumference"
+	SortChestCircumference SortKey = "attributes.chest_circumference"
+	SortWaistCircumference SortKey = "attributes.waist_circumference"
+	SortHipCircumference   SortKey = "attributes.hip_circumference"
+)
+
+var sortKeys = map[SortKey]struct{}{
+	SortPrice: {}, SortName: {}, SortWeight: {}, SortCreatedAt: {}, SortUpdatedAt: {},
+	SortWarrantyMonths: {}, SortFootSize: {}, SortHeadCircumference: {},
+	SortChestCircumference: {}, SortWaistCircumference: {}, SortHipCircumference: {},
+}
+
+type SortField struct {
+	Key  SortKey
+	Desc bool
+}
+
+// ParseSort разбирает параметр sort: ключи через запятую, "-" перед ключом -
+// по убыванию, например "-price,name". Допускаются только ключи из sortKeys.
+func ParseSort(value string) ([]SortField, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	fields := make([]SortField, 0, len(parts))
+	seen := make(map[SortKey]struct{}, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		field := SortField{Key: SortKey(strings.TrimPrefix(part, "-")), Desc: strings.HasPrefix(part, "-")}
+
+		if _, ok := sortKeys[field.Key]; !ok {
+			return nil, fmt.Errorf("%w: unknown sort key %q", ErrInvalidSort, field.Key)
+		}
+		if _, ok := seen[field.Key]; ok {
+			return nil, fmt.Errorf("%w: duplicate sort key %q", ErrInvalidSort, field.Key)
+		}
+		seen[field.Key] = struct{}{}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
 }
+
+// CountMode - способ подсчета общего количества товаров в списке
+type CountMode string
+
+const (
+	CountExact     CountMode = "exact"     // COUNT(*), точно, но дорого на больших выборках
+	CountEstimated CountMode = "estimated" // оценка по статистике планировщика
+)
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type OperationStatus string
+
+const (
+	OperationPending  OperationStatus = "pending"
+	OperationApplied  OperationStatus = "applied"
+	OperationFailed   OperationStatus = "failed"
+	OperationConflict OperationStatus = "conflict" // ожидаемая версия товара устарела
+	OperationStale    OperationStatus = "stale"    // к моменту применения товар уже изменило более новое событие
+)
+
+// Operation - состояние асинхронной команды, принятой API (ID совпадает с EventID)
+type Operation struct {
+	ID        string          `json:"id"`
+	EventType EventType       `json:"event_type"`
+	ProductID int             `json:"product_id"`
+	Status    OperationStatus `json:"status"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
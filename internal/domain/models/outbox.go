@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// OutboxMessage - событие, принятое API и ожидающее публикации в Kafka
+type OutboxMessage struct {
+	ID            int64
+	EventID       string
+	EventType     EventType
+	AggregateKey  string // ключ сообщения в Kafka, задает порядок внутри товара
+	Payload       []byte // ProductEvent в JSON
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
+
+// PublishErrors - результат пакетной публикации: ошибка на позиции события
+// или nil, если событие отправлено
+type PublishErrors []error
+
+func (e PublishErrors) Error() string {
+	failed := 0
+	var first error
+	for _, err := range e {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("failed to publish %d of %d events: %v", failed, len(e), first)
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// PatchFormat - формат документа частичного изменения товара
+type PatchFormat string
+
+const (
+	MergePatch PatchFormat = "merge-patch" // RFC 7396, application/merge-patch+json
+	JSONPatch  PatchFormat = "json-patch"  // RFC 6902, application/json-patch+json
+)
+
+// ApplyPatch применяет документ к JSON-представлению товара и возвращает новый товар.
+// ID, версия и даты служебные: изменения этих полей в документе игнорируются.
+func (p *Product) ApplyPatch(format PatchFormat, doc []byte) (*Product, error) {
+	current, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product: %w", err)
+	}
+
+	var patched []byte
+	switch format {
+	case MergePatch:
+		patched, err = jsonpatch.MergePatch(current, doc)
+	case JSONPatch:
+		var ops jsonpatch.Patch
+		if ops, err = jsonpatch.DecodePatch(doc); err == nil {
+			patched, err = ops.Apply(current)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown patch format %q", ErrInvalidPatch, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var result Product
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	result.ID = p.ID
+	result.Version = p.Version
+	result.CreatedAt = p.CreatedAt
+	result.UpdatedAt = p.UpdatedAt
+
+	return &result, nil
+}
@@ -28,6 +28,7 @@ type Product struct {
 	Type       ProductType `json:"type"`
 	Price      float64     `json:"price"`
 	Attributes Attributes  `json:"attributes"`
+	Version    int64       `json:"version"` // растет на 1 при каждом изменении
 	CreatedAt  time.Time   `json:"created_at"`
 	UpdatedAt  time.Time   `json:"updated_at"`
 }
@@ -0,0 +1,8 @@
+package models
+
+// SearchResult - товар, найденный полнотекстовым поиском
+type SearchResult struct {
+	Product   *Product
+	Rank      float64 // релевантность, больше - выше в выдаче
+	Highlight string  // экранированный как HTML фрагмент текста товара с совпадениями в <mark>...</mark>
+}
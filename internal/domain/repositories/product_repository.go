@@ -2,31 +2,111 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/FollG/kafka-with-go/internal/domain/models"
 )
 
 // ProductRepository определяет контракт для работы с продуктами в БД
 type ProductRepository interface {
+	// NextID резервирует ID нового товара до его вставки
+	NextID(ctx context.Context) (int, error)
+	// NextIDs резервирует n ID одним запросом
+	NextIDs(ctx context.Context, n int) ([]int, error)
 	Create(ctx context.Context, product *models.Product) error
 	GetByID(ctx context.Context, id int) (*models.Product, error)
 	Update(ctx context.Context, product *models.Product) error
 	Delete(ctx context.Context, id int) error
+	// GetByIDs пропускает отсутствующие ID; порядок результата не определен
+	GetByIDs(ctx context.Context, ids []int) ([]*models.Product, error)
 	List(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error)
+	// Count - точное количество товаров по фильтру, EstimateCount - оценка по статистике планировщика
+	Count(ctx context.Context, filter models.ProductFilter) (int, error)
+	EstimateCount(ctx context.Context, filter models.ProductFilter) (int, error)
+	// Search - полнотекстовый поиск с условиями фильтра; возвращает страницу результатов и их общее число
+	Search(ctx context.Context, query string, filter models.ProductFilter) ([]*models.SearchResult, int, error)
+	// Facets - количество товаров по типам и цветам и статистика цен по фильтру
+	Facets(ctx context.Context, filter models.ProductFilter, buckets int) (*models.ProductFacets, error)
+	// Stream передает в fn все товары по фильтру, не загружая выборку в память
+	Stream(ctx context.Context, filter models.ProductFilter, fn func(*models.Product) error) error
+	// ApplyEvent идемпотентно применяет событие: повтор возвращает models.ErrEventAlreadyProcessed.
+	// validate проверяет товар, получившийся после product_patched, до коммита
+	ApplyEvent(ctx context.Context, event *models.ProductEvent, validate func(*models.Product) error) error
+	// ApplyBatch применяет пачку событий одной транзакцией и возвращает примененные
+	ApplyBatch(ctx context.Context, events []*models.ProductEvent) ([]*models.ProductEvent, error)
+	// DeleteProcessedBefore удаляет до limit отметок processed_events старше before
+	DeleteProcessedBefore(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 // ProductCache определяет контракт для кеширования продуктов
 type ProductCache interface {
-	Get(ctx context.Context, key string) (*models.Product, error)
+	// Get возвращает nil без ошибки, если записи нет
+	Get(ctx context.Context, key string) (*models.CachedProduct, error)
+	// Set не перезаписывает карточку с более новой версией товара и надгробие
+	// удаленного товара той же или более новой версии
 	Set(ctx context.Context, key string, product *models.Product) error
+	// SetNotFound кеширует отсутствие товара на короткое время, если ключ пуст
+	SetNotFound(ctx context.Context, key string) error
+	// SetMany записывает карточки одной пачкой (ключ -> товар); ключи, в которых
+	// уже что-то есть, не трогает
+	SetMany(ctx context.Context, products map[string]*models.Product) error
+	// SetDeleted заменяет карточку надгробием с версией удаленной строки;
+	// Get читает его как отсутствие товара
+	SetDeleted(ctx context.Context, key string, version int64) error
 	Delete(ctx context.Context, key string) error
 	SetList(ctx context.Context, key string, products []*models.Product) error
 	GetList(ctx context.Context, key string) ([]*models.Product, error)
+	SetFacets(ctx context.Context, key string, facets *models.ProductFacets) error
+	GetFacets(ctx context.Context, key string) (*models.ProductFacets, error)
+	SetCount(ctx context.Context, key string, count int) error
+	// GetCount возвращает false без ошибки, если записи нет
+	GetCount(ctx context.Context, key string) (int, bool, error)
+	// ListGeneration - текущее поколение списков; оно входит в ключи списков и агрегатов,
+	// поэтому InvalidateLists, увеличивая поколение, разом делает их все недействительными
+	ListGeneration(ctx context.Context) (int64, error)
+	InvalidateLists(ctx context.Context) error
+}
+
+// CacheInvalidator рассылает репликам API ключи, которые нужно убрать из
+// локального кеша
+type CacheInvalidator interface {
+	PublishInvalidation(ctx context.Context, keys ...string) error
+}
+
+// ReadTracker считает прочтения карточек, чтобы прогревать кеш самыми читаемыми товарами
+type ReadTracker interface {
+	TrackRead(id int)
+	MostRead(ctx context.Context, n int) ([]int, error)
+}
+
+// OutboxRepository определяет контракт для transactional outbox событий
+type OutboxRepository interface {
+	Add(ctx context.Context, event *models.ProductEvent) error
+	AddBatch(ctx context.Context, events []*models.ProductEvent) error
+	// AddWithOperations сохраняет события и их операции одной транзакцией
+	AddWithOperations(ctx context.Context, events []*models.ProductEvent, operations []*models.Operation) error
+	// FetchPending захватывает до limit сообщений на время lease, чтобы их не
+	// опубликовал параллельно другой relay
+	FetchPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxMessage, error)
+	MarkSent(ctx context.Context, ids ...int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, nextAttemptAt time.Time) error
+	DeleteSentBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// OperationRepository определяет контракт для статусов асинхронных операций
+type OperationRepository interface {
+	Create(ctx context.Context, operation *models.Operation) error
+	CreateBatch(ctx context.Context, operations []*models.Operation) error
+	GetByID(ctx context.Context, id string) (*models.Operation, error)
+	UpdateStatus(ctx context.Context, status models.OperationStatus, reason string, ids ...string) error
 }
 
 // EventProducer определяет контракт для отправки событий в Kafka
 type EventProducer interface {
 	SendProductEvent(ctx context.Context, event *models.ProductEvent) error
+	// SendProductEvents отправляет события одним вызовом; при частичном сбое
+	// возвращает models.PublishErrors с ошибками по позициям событий
+	SendProductEvents(ctx context.Context, events []*models.ProductEvent) error
 	Close() error
 }
 
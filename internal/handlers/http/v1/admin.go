@@ -0,0 +1,103 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/FollG/kafka-with-go/internal/usecases"
+
+	"github.com/go-chi/render"
+)
+
+type AdminHandler struct {
+	cacheWarmUC *usecases.CacheWarmUseCase
+}
+
+func NewAdminHandler(cacheWarmUC *usecases.CacheWarmUseCase) *AdminHandler {
+	return &AdminHandler{
+		cacheWarmUC: cacheWarmUC,
+	}
+}
+
+type CacheWarmStatusResponse struct {
+	Running bool                 `json:"running"`
+	Last    *usecases.WarmReport `json:"last,omitempty"`
+}
+
+// StartCacheWarm запускает прогрев кеша карточек в фоне:
+// mode=all|recent|most_read, limit - для recent и most_read
+func (h *AdminHandler) StartCacheWarm(w http.ResponseWriter, r *http.Request) {
+	opts := usecases.WarmOptions{
+		Mode: usecases.WarmMode(r.URL.Query().Get("mode")),
+	}
+	if opts.Mode == "" {
+		opts.Mode = usecases.WarmAll
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "invalid_limit",
+				Message: "Limit must be an integer",
+			})
+			return
+		}
+		opts.Limit = limit
+	}
+
+	if err := h.cacheWarmUC.Start(r.Context(), opts); err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrInvalidWarm):
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "invalid_warm_options",
+				Message: err.Error(),
+			})
+		case errors.Is(err, usecases.ErrWarmInProgress):
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "warm_in_progress",
+				Message: err.Error(),
+			})
+		default:
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to start cache warm-up",
+			})
+		}
+		return
+	}
+
+	running, last := h.cacheWarmUC.Status()
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, CacheWarmStatusResponse{Running: running, Last: last})
+}
+
+// GetCacheWarmStatus показывает, идет ли прогрев, и итог последнего запуска
+func (h *AdminHandler) GetCacheWarmStatus(w http.ResponseWriter, r *http.Request) {
+	running, last := h.cacheWarmUC.Status()
+	render.JSON(w, r, CacheWarmStatusResponse{Running: running, Last: last})
+}
+
+// AdminAuthMiddleware пропускает только запросы с Authorization: Bearer <token>
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				render.Status(r, http.StatusUnauthorized)
+				render.JSON(w, r, ErrorResponse{
+					Error:   "unauthorized",
+					Message: "Admin token is required",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+
+	"github.com/go-chi/render"
+)
+
+// maxBatchOperations ограничивает размер пакетного запроса
+const maxBatchOperations = 1000
+
+// BatchProducts принимает пачку create/update/delete одним запросом и
+// возвращает итог по каждому элементу в порядке запроса
+func (h *ProductHandler) BatchProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req BatchProductsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON format",
+		})
+		return
+	}
+
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchOperations {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("Batch must contain from 1 to %d operations", maxBatchOperations),
+		})
+		return
+	}
+
+	items := make([]models.BatchItem, len(req.Operations))
+	for i, op := range req.Operations {
+		items[i] = models.BatchItem{
+			Action:    models.BatchAction(op.Op),
+			ProductID: op.ID,
+			Version:   op.Version,
+		}
+		if op.Product != nil {
+			items[i].Product = productFromRequest(op.Product)
+		}
+	}
+
+	results, err := h.productUC.BatchProducts(ctx, items)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to process batch",
+		})
+		return
+	}
+
+	response := BatchProductsResponse{
+		Results: make([]BatchItemResponse, len(results)),
+	}
+	for i, result := range results {
+		item := BatchItemResponse{
+			Index: i,
+			ID:    result.ProductID,
+		}
+		if result.Err != nil {
+			item.Status = "rejected"
+			item.Error, item.Message = batchItemError(result.Err)
+			response.Rejected++
+		} else {
+			item.Status = "accepted"
+			item.OperationID = result.Operation.ID
+			response.Accepted++
+		}
+		response.Results[i] = item
+	}
+
+	// 202 - принятые элементы обрабатываются асинхронно, итог каждого
+	// смотрим в GET /operations/{operation_id}
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, response)
+}
+
+// batchItemError переводит причину отказа элемента в код ошибки API
+func batchItemError(err error) (string, string) {
+	switch {
+	case errors.Is(err, models.ErrProductNotFound):
+		return "not_found", "Product not found"
+	case errors.Is(err, models.ErrVersionConflict):
+		return "version_conflict", "Product has been modified, fetch it again and retry"
+	case errors.Is(err, models.ErrInvalidProduct):
+		return "validation_error", err.Error()
+	default:
+		return "internal_error", "Failed to check operation"
+	}
+}
+
+func productFromRequest(req *CreateProductRequest) *models.Product {
+	return &models.Product{
+		Name:   req.Name,
+		Weight: req.Weight,
+		Unit:   req.Unit,
+		Color:  req.Color,
+		Type:   models.ProductType(req.Type),
+		Price:  req.Price,
+		Attributes: models.Attributes{
+			Size:               req.Attributes.Size,
+			HeadCircumference:  req.Attributes.HeadCircumference,
+			ChestCircumference: req.Attributes.ChestCircumference,
+			WaistCircumference: req.Attributes.WaistCircumference,
+			HipCircumference:   req.Attributes.HipCircumference,
+			FootSize:           req.Attributes.FootSize,
+			ExpiryDate:         req.Attributes.ExpiryDate,
+			NutritionalInfo:    req.Attributes.NutritionalInfo,
+			WarrantyMonths:     req.Attributes.WarrantyMonths,
+			Voltage:            req.Attributes.Voltage,
+			Dimensions:         req.Attributes.Dimensions,
+			Material:           req.Attributes.Material,
+		},
+	}
+}
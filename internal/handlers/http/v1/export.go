@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/pkg/exporter"
+	"github.com/FollG/kafka-with-go/internal/pkg/logger"
+
+	"github.com/go-chi/render"
+)
+
+// ExportProducts выгружает все товары по фильтру потоком в CSV, NDJSON или Parquet
+func (h *ProductHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format := exporter.Format(r.URL.Query().Get("format"))
+	if format == "" {
+		format = exporter.CSV
+	}
+	switch format {
+	case exporter.CSV, exporter.NDJSON, exporter.Parquet:
+	default:
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_format",
+			Message: "Format must be one of: csv, ndjson, parquet",
+		})
+		return
+	}
+
+	filter := parseProductFilter(r)
+
+	// Выгрузка может идти дольше WriteTimeout сервера
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Error(ctx, "failed to reset write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+
+	writer, err := exporter.NewWriter(w, format)
+	if err == nil {
+		err = h.productUC.ExportProducts(ctx, filter, writer.Write)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		// Заголовки и часть файла уже отправлены: обрываем соединение,
+		// чтобы клиент не принял обрезанный файл за полный
+		logger.Error(ctx, "failed to export products", "format", format, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultPriceBuckets = 10
+	maxPriceBuckets     = 50
+)
+
+// GetFacets возвращает количество товаров по типам и цветам и гистограмму
+// цен для фильтра списка товаров
+func (h *ProductHandler) GetFacets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter := parseProductFilter(r)
+
+	buckets := defaultPriceBuckets
+	if bucketsStr := r.URL.Query().Get("buckets"); bucketsStr != "" {
+		if n, err := strconv.Atoi(bucketsStr); err == nil && n > 0 && n <= maxPriceBuckets {
+			buckets = n
+		}
+	}
+
+	facets, err := h.productUC.GetFacets(ctx, filter, buckets)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get product facets",
+		})
+		return
+	}
+
+	render.JSON(w, r, facets)
+}
@@ -11,10 +11,10 @@ import (
 
 type HealthHandler struct {
 	db    *sql.DB
-	redis *redis.Client
+	redis redis.UniversalClient
 }
 
-func NewHealthHandler(db *sql.DB, redis *redis.Client) *HealthHandler {
+func NewHealthHandler(db *sql.DB, redis redis.UniversalClient) *HealthHandler {
 	return &HealthHandler{
 		db:    db,
 		redis: redis,
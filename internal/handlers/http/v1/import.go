@@ -0,0 +1,103 @@
+package v1
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/FollG/kafka-with-go/internal/pkg/importer"
+	"github.com/FollG/kafka-with-go/internal/usecases"
+
+	"github.com/go-chi/render"
+)
+
+// maxReportedRejects ограничивает число отказов в ответе; счетчик rejected
+// при этом учитывает все строки
+const maxReportedRejects = 1000
+
+type ImportHandler struct {
+	importUC *usecases.ImportUseCase
+}
+
+func NewImportHandler(importUC *usecases.ImportUseCase) *ImportHandler {
+	return &ImportHandler{
+		importUC: importUC,
+	}
+}
+
+// ImportProducts читает файл каталога из тела запроса потоком. Формат задается
+// параметром format или Content-Type: text/csv, application/x-ndjson.
+func (h *ImportHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, ok := importFormat(r)
+	if !ok {
+		render.Status(r, http.StatusUnsupportedMediaType)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "unsupported_media_type",
+			Message: "Use text/csv or application/x-ndjson, or set format=csv|ndjson",
+		})
+		return
+	}
+
+	reader, err := importer.NewReader(r.Body, format)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_file",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response := ImportResponse{
+		Rejects: []ImportRejectResponse{},
+	}
+	report, err := h.importUC.Import(ctx, reader, func(reject usecases.ImportReject) error {
+		if len(response.Rejects) < maxReportedRejects {
+			response.Rejects = append(response.Rejects, ImportRejectResponse{
+				Line:   reject.Line,
+				Reason: reject.Reason,
+			})
+		} else {
+			response.RejectsTruncated = true
+		}
+		return nil
+	})
+	response.Rows = report.Rows
+	response.Imported = report.Imported
+	response.Rejected = report.Rejected
+	if err != nil {
+		// Часть пачек могла быть уже принята, поэтому отчет возвращается и при ошибке
+		response.Error = err.Error()
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, response)
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, response)
+}
+
+func importFormat(r *http.Request) (importer.Format, bool) {
+	switch format := importer.Format(r.URL.Query().Get("format")); format {
+	case importer.CSV, importer.NDJSON:
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return importer.CSV, true
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return importer.NDJSON, true
+	default:
+		return "", false
+	}
+}
@@ -71,6 +71,11 @@ func RecoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				// Обработчик сам оборвал ответ (например, сбой посреди выгрузки)
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
 				logger.Error(r.Context(), "panic_recovered",
 					"error", err,
 					"path", r.URL.Path,
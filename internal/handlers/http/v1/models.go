@@ -39,6 +39,17 @@ type AttributesRequest struct {
 	Material           string     `json:"material,omitempty"`
 }
 
+type BatchProductsRequest struct {
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+type BatchOperationRequest struct {
+	Op      string                `json:"op"`                // create, update, delete
+	ID      int                   `json:"id,omitempty"`      // для update и delete
+	Version int64                 `json:"version,omitempty"` // как If-Match, 0 - без проверки
+	Product *CreateProductRequest `json:"product,omitempty"` // для create и update
+}
+
 type ProductResponse struct {
 	ID         int                `json:"id"`
 	Name       string             `json:"name"`
@@ -48,6 +59,7 @@ type ProductResponse struct {
 	Type       string             `json:"type"`
 	Price      float64            `json:"price"`
 	Attributes AttributesResponse `json:"attributes"`
+	Version    int64              `json:"version"`
 	CreatedAt  time.Time          `json:"created_at"`
 	UpdatedAt  time.Time          `json:"updated_at"`
 }
@@ -68,26 +80,92 @@ type AttributesResponse struct {
 }
 
 type CreateProductResponse struct {
-	ID      int    `json:"id"`
-	Message string `json:"message"`
-	Status  string `json:"status"`
+	ID          int    `json:"id"`
+	OperationID string `json:"operation_id"`
+	Message     string `json:"message"`
+	Status      string `json:"status"`
 }
 
 type UpdateProductResponse struct {
-	Message string `json:"message"`
-	Status  string `json:"status"`
+	OperationID string `json:"operation_id"`
+	Message     string `json:"message"`
+	Status      string `json:"status"`
+}
+
+type PatchProductResponse struct {
+	OperationID string `json:"operation_id"`
+	Message     string `json:"message"`
+	Status      string `json:"status"`
 }
 
 type DeleteProductResponse struct {
-	Message string `json:"message"`
-	Status  string `json:"status"`
+	OperationID string `json:"operation_id"`
+	Message     string `json:"message"`
+	Status      string `json:"status"`
+}
+
+type OperationResponse struct {
+	ID        string    `json:"id"`
+	EventType string    `json:"event_type"`
+	ProductID int       `json:"product_id"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type ListProductsResponse struct {
-	Products []ProductResponse `json:"products"`
-	Total    int               `json:"total"`
-	Limit    int               `json:"limit"`
-	Offset   int               `json:"offset"`
+	Products       []ProductResponse `json:"products"`
+	Total          int               `json:"total"`                     // всего товаров по фильтру
+	TotalEstimated bool              `json:"total_estimated,omitempty"` // total - оценка планировщика (count=estimated)
+	HasMore        bool              `json:"has_more"`
+	Limit          int               `json:"limit"`
+	Offset         int               `json:"offset"`
+	NextCursor     string            `json:"next_cursor,omitempty"` // пусто на последней странице
+	PrevCursor     string            `json:"prev_cursor,omitempty"` // пусто на первой странице
+}
+
+type SearchProductsResponse struct {
+	Results []SearchResultResponse `json:"results"`
+	Total   int                    `json:"total"`
+	HasMore bool                   `json:"has_more"`
+	Limit   int                    `json:"limit"`
+	Offset  int                    `json:"offset"`
+}
+
+type SearchResultResponse struct {
+	ProductResponse
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"` // совпадения выделены <mark>...</mark>
+}
+
+type BatchProductsResponse struct {
+	Results  []BatchItemResponse `json:"results"`
+	Accepted int                 `json:"accepted"`
+	Rejected int                 `json:"rejected"`
+}
+
+type BatchItemResponse struct {
+	Index       int    `json:"index"`
+	Status      string `json:"status"` // accepted, rejected
+	ID          int    `json:"id,omitempty"`
+	OperationID string `json:"operation_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+type ImportResponse struct {
+	Rows             int                    `json:"rows"`
+	Imported         int                    `json:"imported"`
+	Rejected         int                    `json:"rejected"`
+	Rejects          []ImportRejectResponse `json:"rejects"`
+	RejectsTruncated bool                   `json:"rejects_truncated,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+}
+
+type ImportRejectResponse struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
 }
 
 type ErrorResponse struct {
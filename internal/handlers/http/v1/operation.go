@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/usecases"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type OperationHandler struct {
+	operationUC *usecases.OperationUseCase
+}
+
+func NewOperationHandler(operationUC *usecases.OperationUseCase) *OperationHandler {
+	return &OperationHandler{
+		operationUC: operationUC,
+	}
+}
+
+// GetOperation возвращает статус асинхронной операции
+func (h *OperationHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := chi.URLParam(r, "id")
+
+	operation, err := h.operationUC.GetOperation(ctx, id)
+	if err != nil {
+		if err == models.ErrOperationNotFound {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "not_found",
+				Message: "Operation not found",
+			})
+			return
+		}
+
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get operation",
+		})
+		return
+	}
+
+	render.JSON(w, r, OperationResponse{
+		ID:        operation.ID,
+		EventType: string(operation.EventType),
+		ProductID: operation.ProductID,
+		Status:    string(operation.Status),
+		Error:     operation.Error,
+		CreatedAt: operation.CreatedAt,
+		UpdatedAt: operation.UpdatedAt,
+	})
+}
+
+// setOperationLocation указывает клиенту, где следить за статусом операции
+func setOperationLocation(w http.ResponseWriter, operation *models.Operation) {
+	w.Header().Set("Location", "/api/v1/operations/"+operation.ID)
+}
@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken - содержимое непрозрачного cursor / next_cursor / prev_cursor.
+// Клиент передает токен как есть и не должен разбирать его сам.
+type cursorToken struct {
+	CreatedAt time.Time `json:"c"`
+	ID        int       `json:"i"`
+	Backward  bool      `json:"b,omitempty"` // страница перед курсором, а не после
+}
+
+func encodeCursor(cursor models.ProductCursor, backward bool) string {
+	data, _ := json.Marshal(cursorToken{CreatedAt: cursor.CreatedAt, ID: cursor.ID, Backward: backward})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (*models.ProductCursor, bool, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, false, errInvalidCursor
+	}
+
+	var t cursorToken
+	if err := json.Unmarshal(data, &t); err != nil || t.ID <= 0 || t.CreatedAt.IsZero() {
+		return nil, false, errInvalidCursor
+	}
+
+	return &models.ProductCursor{CreatedAt: t.CreatedAt, ID: t.ID}, t.Backward, nil
+}
+
+// pageLink - ссылка RFC 8288 на другую страницу того же списка: фильтры и
+// limit запроса сохраняются, offset и cursor заменяются на params
+func pageLink(r *http.Request, rel string, params map[string]string) string {
+	query := r.URL.Query()
+	query.Del("offset")
+	query.Del("cursor")
+	for key, value := range params {
+		query.Set(key, value)
+	}
+
+	target := r.URL.Path
+	if encoded := query.Encode(); encoded != "" {
+		target += "?" + encoded
+	}
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, target, rel)
+}
+
+func setLinkHeader(w http.ResponseWriter, links []string) {
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
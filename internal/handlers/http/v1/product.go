@@ -1,10 +1,16 @@
 package v1
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/FollG/kafka-with-go/internal/domain/models"
 	"github.com/FollG/kafka-with-go/internal/usecases"
@@ -59,9 +65,10 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	if err := h.productUC.CreateProduct(ctx, product); err != nil {
+	operation, err := h.productUC.CreateProduct(ctx, product)
+	if err != nil {
 		switch {
-		case strings.Contains(err.Error(), "validation failed"):
+		case errors.Is(err, models.ErrInvalidProduct):
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, ErrorResponse{
 				Error:   "validation_error",
@@ -77,11 +84,13 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setOperationLocation(w, operation)
 	render.Status(r, http.StatusAccepted) // 202 Accepted - операция принята в обработку
 	render.JSON(w, r, CreateProductResponse{
-		ID:      product.ID,
-		Message: "Product creation accepted",
-		Status:  "processing",
+		ID:          product.ID,
+		OperationID: operation.ID,
+		Message:     "Product creation accepted",
+		Status:      "processing",
 	})
 }
 
@@ -127,6 +136,7 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", productETag(product))
 	render.JSON(w, r, ProductResponse{
 		ID:     product.ID,
 		Name:   product.Name,
@@ -149,6 +159,7 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 			Dimensions:         product.Attributes.Dimensions,
 			Material:           product.Attributes.Material,
 		},
+		Version:   product.Version,
 		CreatedAt: product.CreatedAt,
 		UpdatedAt: product.UpdatedAt,
 	})
@@ -157,10 +168,8 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	filter := models.ProductFilter{
-		Limit:  25, // дефолтный лимит
-		Offset: 0,
-	}
+	filter := parseProductFilter(r)
+	filter.Limit = 25 // дефолтный лимит
 
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 100 {
@@ -168,35 +177,68 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
-			filter.Offset = offset
-		}
+	countMode := models.CountMode(r.URL.Query().Get("count"))
+	switch countMode {
+	case "":
+		countMode = models.CountExact
+	case models.CountExact, models.CountEstimated:
+	default:
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_count",
+			Message: "Count must be one of: exact, estimated",
+		})
+		return
 	}
 
-	if minPriceStr := r.URL.Query().Get("min_price"); minPriceStr != "" {
-		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil && minPrice >= 0 {
-			filter.MinPrice = &minPrice
-		}
+	sort, err := models.ParseSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_sort",
+			Message: err.Error(),
+		})
+		return
 	}
+	filter.Sort = sort
 
-	if maxPriceStr := r.URL.Query().Get("max_price"); maxPriceStr != "" {
-		if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil && maxPrice >= 0 {
-			filter.MaxPrice = &maxPrice
+	backward := false
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		// Курсор хранит позицию только в порядке по умолчанию (created_at, id)
+		if len(filter.Sort) > 0 {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "invalid_cursor",
+				Message: "Cursor pagination is not supported together with sort; use offset",
+			})
+			return
 		}
-	}
 
-	if color := r.URL.Query().Get("color"); color != "" {
-		filter.Color = color
-	}
-
-	if types := r.URL.Query()["type"]; len(types) > 0 {
-		filter.Types = make([]models.ProductType, len(types))
-		for i, t := range types {
-			filter.Types[i] = models.ProductType(t)
+		position, isBackward, err := decodeCursor(cursor)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "invalid_cursor",
+				Message: "Cursor is malformed; use next_cursor or prev_cursor from a previous response",
+			})
+			return
+		}
+		backward = isBackward
+		if backward {
+			filter.Before = position
+		} else {
+			filter.After = position
+		}
+	} else if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filter.Offset = offset
 		}
 	}
 
+	// Лишняя строка показывает, есть ли еще страница в направлении обхода
+	pageSize := filter.Limit
+	filter.Limit++
+
 	products, err := h.productUC.ListProducts(ctx, filter)
 	if err != nil {
 		render.Status(r, http.StatusInternalServerError)
@@ -207,44 +249,100 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	total, err := h.productUC.CountProducts(ctx, filter, countMode)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to count products",
+		})
+		return
+	}
+
+	var hasNext, hasPrev bool
+	if backward {
+		// При обходе назад лишняя строка - самая дальняя от курсора, то есть первая
+		hasPrev = len(products) > pageSize
+		if hasPrev {
+			products = products[len(products)-pageSize:]
+		}
+		// Строка курсора могла быть удалена, и после страницы ничего не осталось:
+		// следующую страницу проверяет отдельный запрос на одну строку вперед
+		if len(products) > 0 {
+			hasNext, err = h.hasProductsAfter(ctx, filter, products[len(products)-1])
+			if err != nil {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, ErrorResponse{
+					Error:   "internal_error",
+					Message: "Failed to list products",
+				})
+				return
+			}
+		}
+	} else {
+		hasNext = len(products) > pageSize
+		if hasNext {
+			products = products[:pageSize]
+		}
+		hasPrev = filter.After != nil || filter.Offset > 0
+	}
+
 	response := ListProductsResponse{
-		Products: make([]ProductResponse, len(products)),
-		Total:    len(products),
-		Limit:    filter.Limit,
-		Offset:   filter.Offset,
+		Products:       make([]ProductResponse, len(products)),
+		Total:          total,
+		TotalEstimated: countMode == models.CountEstimated,
+		HasMore:        hasNext,
+		Limit:          pageSize,
+		Offset:         filter.Offset,
 	}
 
-	for i, product := range products {
-		response.Products[i] = ProductResponse{
-			ID:     product.ID,
-			Name:   product.Name,
-			Weight: product.Weight,
-			Unit:   product.Unit,
-			Color:  product.Color,
-			Type:   string(product.Type),
-			Price:  product.Price,
-			Attributes: AttributesResponse{
-				Size:               product.Attributes.Size,
-				HeadCircumference:  product.Attributes.HeadCircumference,
-				ChestCircumference: product.Attributes.ChestCircumference,
-				WaistCircumference: product.Attributes.WaistCircumference,
-				HipCircumference:   product.Attributes.HipCircumference,
-				FootSize:           product.Attributes.FootSize,
-				ExpiryDate:         product.Attributes.ExpiryDate,
-				NutritionalInfo:    product.Attributes.NutritionalInfo,
-				WarrantyMonths:     product.Attributes.WarrantyMonths,
-				Voltage:            product.Attributes.Voltage,
-				Dimensions:         product.Attributes.Dimensions,
-				Material:           product.Attributes.Material,
-			},
-			CreatedAt: product.CreatedAt,
-			UpdatedAt: product.UpdatedAt,
+	links := []string{pageLink(r, "first", nil)}
+	if len(products) > 0 && len(filter.Sort) == 0 {
+		if hasNext {
+			response.NextCursor = encodeCursor(models.CursorOf(products[len(products)-1]), false)
+		}
+		if hasPrev {
+			response.PrevCursor = encodeCursor(models.CursorOf(products[0]), true)
 		}
 	}
 
+	// В режиме offset ссылки остаются на offset, в режиме cursor - на курсоры
+	keyset := filter.After != nil || filter.Before != nil
+	switch {
+	case keyset && response.PrevCursor != "":
+		links = append(links, pageLink(r, "prev", map[string]string{"cursor": response.PrevCursor}))
+	case !keyset && hasPrev:
+		links = append(links, pageLink(r, "prev", map[string]string{"offset": strconv.Itoa(max(filter.Offset-pageSize, 0))}))
+	}
+	switch {
+	case keyset && response.NextCursor != "":
+		links = append(links, pageLink(r, "next", map[string]string{"cursor": response.NextCursor}))
+	case !keyset && hasNext:
+		links = append(links, pageLink(r, "next", map[string]string{"offset": strconv.Itoa(filter.Offset + pageSize)}))
+	}
+	setLinkHeader(w, links)
+
+	for i, product := range products {
+		response.Products[i] = productResponse(product)
+	}
+
 	render.JSON(w, r, response)
 }
 
+// hasProductsAfter проверяет, есть ли под фильтром товары после product в порядке по умолчанию
+func (h *ProductHandler) hasProductsAfter(ctx context.Context, filter models.ProductFilter, product *models.Product) (bool, error) {
+	position := models.CursorOf(product)
+	filter.After = &position
+	filter.Before = nil
+	filter.Limit = 1
+
+	products, err := h.productUC.ListProducts(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return len(products) > 0, nil
+}
+
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -259,6 +357,16 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_if_match",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	var req UpdateProductRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		render.Status(r, http.StatusBadRequest)
@@ -293,7 +401,8 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	if err := h.productUC.UpdateProduct(ctx, product); err != nil {
+	operation, err := h.productUC.UpdateProduct(ctx, product, expectedVersion)
+	if err != nil {
 		switch {
 		case err == models.ErrProductNotFound:
 			render.Status(r, http.StatusNotFound)
@@ -301,7 +410,9 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 				Error:   "not_found",
 				Message: "Product not found",
 			})
-		case strings.Contains(err.Error(), "validation failed"):
+		case err == models.ErrVersionConflict:
+			renderVersionConflict(w, r)
+		case errors.Is(err, models.ErrInvalidProduct):
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, ErrorResponse{
 				Error:   "validation_error",
@@ -317,10 +428,100 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setOperationLocation(w, operation)
 	render.Status(r, http.StatusAccepted)
 	render.JSON(w, r, UpdateProductResponse{
-		Message: "Product update accepted",
-		Status:  "processing",
+		OperationID: operation.ID,
+		Message:     "Product update accepted",
+		Status:      "processing",
+	})
+}
+
+// PatchProduct частично изменяет товар: application/merge-patch+json (RFC 7396,
+// также принимается application/json) или application/json-patch+json (RFC 6902)
+func (h *ProductHandler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid product ID",
+		})
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_if_match",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	format, ok := patchFormat(r)
+	if !ok {
+		render.Status(r, http.StatusUnsupportedMediaType)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "unsupported_media_type",
+			Message: "Use application/merge-patch+json or application/json-patch+json",
+		})
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil || !json.Valid(patch) {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON format",
+		})
+		return
+	}
+
+	operation, err := h.productUC.PatchProduct(ctx, id, format, patch, expectedVersion)
+	if err != nil {
+		switch {
+		case err == models.ErrProductNotFound:
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "not_found",
+				Message: "Product not found",
+			})
+		case err == models.ErrVersionConflict:
+			renderVersionConflict(w, r)
+		case errors.Is(err, models.ErrInvalidPatch):
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "invalid_patch",
+				Message: err.Error(),
+			})
+		case errors.Is(err, models.ErrInvalidProduct):
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+		default:
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to patch product",
+			})
+		}
+		return
+	}
+
+	setOperationLocation(w, operation)
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, PatchProductResponse{
+		OperationID: operation.ID,
+		Message:     "Product patch accepted",
+		Status:      "processing",
 	})
 }
 
@@ -338,7 +539,18 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.productUC.DeleteProduct(ctx, id); err != nil {
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_if_match",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	operation, err := h.productUC.DeleteProduct(ctx, id, expectedVersion)
+	if err != nil {
 		if err == models.ErrProductNotFound {
 			render.Status(r, http.StatusNotFound)
 			render.JSON(w, r, ErrorResponse{
@@ -347,6 +559,10 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		if err == models.ErrVersionConflict {
+			renderVersionConflict(w, r)
+			return
+		}
 
 		render.Status(r, http.StatusInternalServerError)
 		render.JSON(w, r, ErrorResponse{
@@ -356,9 +572,126 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setOperationLocation(w, operation)
 	render.Status(r, http.StatusAccepted)
 	render.JSON(w, r, DeleteProductResponse{
-		Message: "Product deletion accepted",
-		Status:  "processing",
+		OperationID: operation.ID,
+		Message:     "Product deletion accepted",
+		Status:      "processing",
 	})
 }
+
+// productETag - сильный ETag по версии товара
+func productETag(product *models.Product) string {
+	return fmt.Sprintf("%q", strconv.FormatInt(product.Version, 10))
+}
+
+// parseIfMatch возвращает ожидаемую версию из If-Match. Без заголовка
+// или с "*" версия не проверяется (0). Слабые ETag (W/"n") тоже принимаются.
+func parseIfMatch(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	tag := strings.TrimPrefix(value, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, fmt.Errorf("If-Match must be a quoted product version")
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("If-Match must be a quoted product version")
+	}
+
+	return version, nil
+}
+
+func renderVersionConflict(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusPreconditionFailed)
+	render.JSON(w, r, ErrorResponse{
+		Error:   "version_conflict",
+		Message: "Product has been modified, fetch it again and retry",
+	})
+}
+
+// maxPatchSize ограничивает размер документа в PATCH
+const maxPatchSize = 1 << 20
+
+// patchFormat определяет формат патча по Content-Type
+func patchFormat(r *http.Request) (models.PatchFormat, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case "application/merge-patch+json", "application/json":
+		return models.MergePatch, true
+	case "application/json-patch+json":
+		return models.JSONPatch, true
+	default:
+		return "", false
+	}
+}
+
+// parseProductFilter читает условия фильтра, общие для списка и выгрузки;
+// некорректные значения игнорируются
+func parseProductFilter(r *http.Request) models.ProductFilter {
+	var filter models.ProductFilter
+
+	if minPriceStr := r.URL.Query().Get("min_price"); minPriceStr != "" {
+		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil && minPrice >= 0 {
+			filter.MinPrice = &minPrice
+		}
+	}
+
+	if maxPriceStr := r.URL.Query().Get("max_price"); maxPriceStr != "" {
+		if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil && maxPrice >= 0 {
+			filter.MaxPrice = &maxPrice
+		}
+	}
+
+	if color := r.URL.Query().Get("color"); color != "" {
+		filter.Color = color
+	}
+
+	if types := r.URL.Query()["type"]; len(types) > 0 {
+		filter.Types = make([]models.ProductType, len(types))
+		for i, t := range types {
+			filter.Types[i] = models.ProductType(t)
+		}
+	}
+
+	query := r.URL.Query()
+	filter.Size = query.Get("size")
+	filter.Material = query.Get("material")
+	filter.Voltage = query.Get("voltage")
+
+	if v, err := strconv.Atoi(query.Get("min_warranty_months")); err == nil && v >= 0 {
+		filter.MinWarrantyMonths = &v
+	}
+	if v, err := strconv.Atoi(query.Get("max_warranty_months")); err == nil && v >= 0 {
+		filter.MaxWarrantyMonths = &v
+	}
+	if v, err := strconv.ParseFloat(query.Get("min_foot_size"), 64); err == nil && v > 0 {
+		filter.MinFootSize = &v
+	}
+	if v, err := strconv.ParseFloat(query.Get("max_foot_size"), 64); err == nil && v > 0 {
+		filter.MaxFootSize = &v
+	}
+	filter.ExpiresBefore = parseDateParam(query.Get("expires_before"))
+	filter.ExpiresAfter = parseDateParam(query.Get("expires_after"))
+
+	return filter
+}
+
+// parseDateParam принимает дату YYYY-MM-DD или RFC 3339; иначе nil
+func parseDateParam(value string) *time.Time {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return &date
+		}
+	}
+	return nil
+}
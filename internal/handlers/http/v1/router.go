@@ -13,9 +13,13 @@ import (
 
 func NewRouter(
 	productUC *usecases.ProductUseCase,
+	operationUC *usecases.OperationUseCase,
+	importUC *usecases.ImportUseCase,
+	cacheWarmUC *usecases.CacheWarmUseCase,
 	db *sql.DB,
-	redisClient *redis.Client,
+	redisClient redis.UniversalClient,
 	rateLimit int,
+	adminToken string,
 ) http.Handler {
 	r := chi.NewRouter()
 
@@ -32,20 +36,43 @@ func NewRouter(
 	healthHandler.RegisterRoutes(healthRouter)
 	r.Mount("/health", healthRouter)
 
+	// Служебные операции; без ADMIN_TOKEN не подключаются
+	if adminToken != "" {
+		adminHandler := NewAdminHandler(cacheWarmUC)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(AdminAuthMiddleware(adminToken))
+			r.Post("/cache/warm", adminHandler.StartCacheWarm)
+			r.Get("/cache/warm", adminHandler.GetCacheWarmStatus)
+		})
+	}
+
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
 		productHandler := NewProductHandler(productUC)
 
+		// Пакетные изменения считаются одним запросом для RateLimitMiddleware
+		r.Post("/products:batch", productHandler.BatchProducts)
+
 		r.Route("/products", func(r chi.Router) {
 			r.Post("/", productHandler.CreateProduct)
 			r.Get("/", productHandler.ListProducts)
+			r.Get("/export", productHandler.ExportProducts)
+			r.Get("/search", productHandler.SearchProducts)
+			r.Get("/facets", productHandler.GetFacets)
 
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", productHandler.GetProduct)
 				r.Put("/", productHandler.UpdateProduct)
+				r.Patch("/", productHandler.PatchProduct)
 				r.Delete("/", productHandler.DeleteProduct)
 			})
 		})
+
+		operationHandler := NewOperationHandler(operationUC)
+		importHandler := NewImportHandler(importUC)
+
+		r.Get("/operations/{id}", operationHandler.GetOperation)
+		r.Post("/imports", importHandler.ImportProducts)
 	})
 
 	return r
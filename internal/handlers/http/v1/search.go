@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+
+	"github.com/go-chi/render"
+)
+
+// maxSearchQueryLength ограничивает длину строки поиска
+const maxSearchQueryLength = 200
+
+// SearchProducts - полнотекстовый поиск по названию, цвету, материалу и
+// пищевой ценности с фильтрами списка товаров
+func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" || len(q) > maxSearchQueryLength {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "invalid_query",
+			Message: "Query parameter q is required and must not exceed 200 bytes",
+		})
+		return
+	}
+
+	filter := parseProductFilter(r)
+	filter.Limit = 25 // дефолтный лимит
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 100 {
+			filter.Limit = limit
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filter.Offset = offset
+		}
+	}
+
+	results, total, err := h.productUC.SearchProducts(ctx, q, filter)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to search products",
+		})
+		return
+	}
+
+	response := SearchProductsResponse{
+		Results: make([]SearchResultResponse, len(results)),
+		Total:   total,
+		HasMore: filter.Offset+len(results) < total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	}
+	for i, result := range results {
+		response.Results[i] = SearchResultResponse{
+			ProductResponse: productResponse(result.Product),
+			Rank:            result.Rank,
+			Highlight:       result.Highlight,
+		}
+	}
+
+	render.JSON(w, r, response)
+}
+
+func productResponse(product *models.Product) ProductResponse {
+	return ProductResponse{
+		ID:     product.ID,
+		Name:   product.Name,
+		Weight: product.Weight,
+		Unit:   product.Unit,
+		Color:  product.Color,
+		Type:   string(product.Type),
+		Price:  product.Price,
+		Attributes: AttributesResponse{
+			Size:               product.Attributes.Size,
+			HeadCircumference:  product.Attributes.HeadCircumference,
+			ChestCircumference: product.Attributes.ChestCircumference,
+			WaistCircumference: product.Attributes.WaistCircumference,
+			HipCircumference:   product.Attributes.HipCircumference,
+			FootSize:           product.Attributes.FootSize,
+			ExpiryDate:         product.Attributes.ExpiryDate,
+			NutritionalInfo:    product.Attributes.NutritionalInfo,
+			WarrantyMonths:     product.Attributes.WarrantyMonths,
+			Voltage:            product.Attributes.Voltage,
+			Dimensions:         product.Attributes.Dimensions,
+			Material:           product.Attributes.Material,
+		},
+		Version:   product.Version,
+		CreatedAt: product.CreatedAt,
+		UpdatedAt: product.UpdatedAt,
+	}
+}
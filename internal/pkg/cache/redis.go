@@ -3,23 +3,82 @@ package cache
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/FollG/kafka-with-go/internal/pkg/config"
 
 	"github.com/redis/go-redis/v9"
 )
 
-func NewRedisClient(cfg config.RedisConfig) (*redis.Client, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+// Режимы подключения к Redis
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+)
+
+// NewRedisClient создает клиент для одиночного узла, sentinel или кластера
+// в зависимости от cfg.Mode. Адаптеры работают с redis.UniversalClient и
+// не знают, какая топология за ним стоит.
+func NewRedisClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	client, err := newUniversalClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Проверка соединения
 	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
 	return client, nil
 }
+
+func newUniversalClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	addrs := make([]string, 0, len(cfg.Addrs))
+	for _, addr := range cfg.Addrs {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	switch cfg.Mode {
+	case "", ModeSingle:
+		return redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+		}), nil
+
+	case ModeSentinel:
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires master name")
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("redis sentinel mode requires sentinel addresses")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+		}), nil
+
+	case ModeCluster:
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("redis cluster mode requires node addresses")
+		}
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("redis cluster supports only db 0, got %d", cfg.DB)
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    addrs,
+			Password: cfg.Password,
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+}
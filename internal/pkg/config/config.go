@@ -8,11 +8,15 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Kafka    KafkaConfig
-	Redis    RedisConfig
-	Metrics  MetricsConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Kafka      KafkaConfig
+	Redis      RedisConfig
+	LocalCache LocalCacheConfig
+	CacheWarm  CacheWarmConfig
+	Metrics    MetricsConfig
+	Outbox     OutboxConfig
+	Import     ImportConfig
 }
 
 type ServerConfig struct {
@@ -20,6 +24,7 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	RateLimit    int
+	AdminToken   string // токен /admin/*; пустой - служебные маршруты выключены
 }
 
 type DatabaseConfig struct {
@@ -37,20 +42,68 @@ type KafkaConfig struct {
 	Brokers       []string
 	Topic         string
 	ConsumerGroup string
+	Workers       int
+	BatchMode     bool
+	BatchSize     int
+	BatchTimeout  time.Duration
 	EnableTLS     bool
+	RetryTopics   []string
+	RetryDelays   []time.Duration
+	DLQTopic      string
+
+	// ProcessedRetention - сколько хранить отметки processed_events; должно быть
+	// больше retention топика вместе с retry-задержками
+	ProcessedRetention       time.Duration
+	ProcessedCleanupInterval time.Duration
 }
 
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
-	TTL      time.Duration
+	Mode             string   // single, sentinel, cluster
+	Addr             string   // адрес в режиме single
+	Addrs            []string // адреса sentinel'ов или узлов кластера
+	MasterName       string   // имя master'а, за которым следят sentinel'ы
+	SentinelPassword string
+	Password         string
+	DB               int // в режиме cluster только 0
+	TTL              time.Duration
+	StaleTTL         time.Duration // сколько отдавать устаревшую карточку, пока она обновляется в фоне
+	NegativeTTL      time.Duration // сколько помнить, что товара нет
+
+	InvalidationChannel string // pub/sub канал инвалидаций локальных кешей
+
+	Codec       string // формат значений: json, msgpack
+	Compression string // сжатие значений: none, zstd, snappy
+}
+
+// LocalCacheConfig - LRU-кеш карточек в памяти реплики API перед Redis
+type LocalCacheConfig struct {
+	Size int // 0 - локальный кеш выключен
+	TTL  time.Duration
 }
 
 type MetricsConfig struct {
 	Port int
 }
 
+// CacheWarmConfig - прогрев кеша карточек (cmd/cachewarm, /admin/cache/warm)
+type CacheWarmConfig struct {
+	BatchSize int // карточек в одном pipeline
+	Rate      int // карточек в секунду; 0 - без ограничения
+}
+
+type ImportConfig struct {
+	BatchSize int
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	Retention    time.Duration
+	Lease        time.Duration // на сколько relay захватывает пачку сообщений
+}
+
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
@@ -58,6 +111,7 @@ func Load() *Config {
 			ReadTimeout:  getEnvAsDuration("SERVER_READ_TIMEOUT", 30*time.Second),
 			WriteTimeout: getEnvAsDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
 			RateLimit:    getEnvAsInt("RATE_LIMIT", 10),
+			AdminToken:   getEnv("ADMIN_TOKEN", ""),
 		},
 		Database: DatabaseConfig{
 			Host:         getEnv("DB_HOST", "localhost"),
@@ -73,17 +127,57 @@ func Load() *Config {
 			Brokers:       getEnvAsSlice("KAFKA_BROKERS", []string{"localhost:9092"}, ","),
 			Topic:         getEnv("KAFKA_TOPIC", "products"),
 			ConsumerGroup: getEnv("KAFKA_CONSUMER_GROUP", "product-processor"),
+			Workers:       getEnvAsInt("KAFKA_CONSUMER_WORKERS", 8),
+			BatchMode:     getEnvAsBool("KAFKA_BATCH_MODE", false),
+			BatchSize:     getEnvAsInt("KAFKA_BATCH_SIZE", 100),
+			BatchTimeout:  getEnvAsDuration("KAFKA_BATCH_TIMEOUT", 100*time.Millisecond),
 			EnableTLS:     getEnvAsBool("KAFKA_ENABLE_TLS", false),
+			RetryTopics:   getEnvAsSlice("KAFKA_RETRY_TOPICS", []string{"products.retry.1m", "products.retry.10m"}, ","),
+			RetryDelays:   getEnvAsDurationSlice("KAFKA_RETRY_DELAYS", []time.Duration{time.Minute, 10 * time.Minute}, ","),
+			DLQTopic:      getEnv("KAFKA_DLQ_TOPIC", "products.dlq"),
+
+			ProcessedRetention:       getEnvAsDuration("PROCESSED_EVENTS_RETENTION", 8*24*time.Hour),
+			ProcessedCleanupInterval: getEnvAsDuration("PROCESSED_EVENTS_CLEANUP_INTERVAL", time.Hour),
 		},
 		Redis: RedisConfig{
-			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
-			TTL:      getEnvAsDuration("REDIS_TTL", 5*time.Minute),
+			Mode:             getEnv("REDIS_MODE", "single"),
+			Addr:             getEnv("REDIS_ADDR", "localhost:6379"),
+			Addrs:            getEnvAsSlice("REDIS_ADDRS", nil, ","),
+			MasterName:       getEnv("REDIS_MASTER_NAME", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+			Password:         getEnv("REDIS_PASSWORD", ""),
+			DB:               getEnvAsInt("REDIS_DB", 0),
+			TTL:              getEnvAsDuration("REDIS_TTL", 5*time.Minute),
+			StaleTTL:         getEnvAsDuration("REDIS_STALE_TTL", time.Minute),
+			NegativeTTL:      getEnvAsDuration("REDIS_NEGATIVE_TTL", 30*time.Second),
+
+			InvalidationChannel: getEnv("REDIS_INVALIDATION_CHANNEL", "products:cache:invalidate"),
+
+			Codec:       getEnv("REDIS_CODEC", "json"),
+			Compression: getEnv("REDIS_COMPRESSION", "none"),
+		},
+		CacheWarm: CacheWarmConfig{
+			BatchSize: getEnvAsInt("CACHE_WARM_BATCH_SIZE", 500),
+			Rate:      getEnvAsInt("CACHE_WARM_RATE", 5000),
+		},
+		LocalCache: LocalCacheConfig{
+			Size: getEnvAsInt("LOCAL_CACHE_SIZE", 10000),
+			TTL:  getEnvAsDuration("LOCAL_CACHE_TTL", 10*time.Second),
 		},
 		Metrics: MetricsConfig{
 			Port: getEnvAsInt("METRICS_PORT", 9091),
 		},
+		Outbox: OutboxConfig{
+			PollInterval: getEnvAsDuration("OUTBOX_POLL_INTERVAL", 200*time.Millisecond),
+			BatchSize:    getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+			MinBackoff:   getEnvAsDuration("OUTBOX_MIN_BACKOFF", time.Second),
+			MaxBackoff:   getEnvAsDuration("OUTBOX_MAX_BACKOFF", 5*time.Minute),
+			Retention:    getEnvAsDuration("OUTBOX_RETENTION", 24*time.Hour),
+			Lease:        getEnvAsDuration("OUTBOX_LEASE", 30*time.Second),
+		},
+		Import: ImportConfig{
+			BatchSize: getEnvAsInt("IMPORT_BATCH_SIZE", 500),
+		},
 	}
 }
 
@@ -126,3 +220,21 @@ func getEnvAsSlice(key string, defaultValue []string, sep string) []string {
 	}
 	return strings.Split(valueStr, sep)
 }
+
+func getEnvAsDurationSlice(key string, defaultValue []time.Duration, sep string) []time.Duration {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	parts := strings.Split(valueStr, sep)
+	values := make([]time.Duration, 0, len(parts))
+	for _, part := range parts {
+		value, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return defaultValue
+		}
+		values = append(values, value)
+	}
+	return values
+}
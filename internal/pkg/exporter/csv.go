@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// csvHeader - колонки CSV в порядке CSVWriter.Write
+var csvHeader = []string{
+	"id", "name", "weight", "unit", "color", "type", "price",
+	"size", "head_circumference", "chest_circumference", "waist_circumference",
+	"hip_circumference", "foot_size", "expiry_date", "nutritional_info",
+	"warranty_months", "voltage", "dimensions", "material",
+	"version", "created_at", "updated_at",
+}
+
+type CSVWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+// NewCSVWriter сразу пишет заголовок
+func NewCSVWriter(w io.Writer) (*CSVWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, fmt.Errorf("failed to write csv header: %w", err)
+	}
+
+	return &CSVWriter{
+		writer: writer,
+		record: make([]string, len(csvHeader)),
+	}, nil
+}
+
+func (w *CSVWriter) Write(product *models.Product) error {
+	row := NewRow(product)
+
+	w.record = append(w.record[:0],
+		strconv.FormatInt(row.ID, 10),
+		row.Name,
+		formatFloat(row.Weight),
+		row.Unit,
+		row.Color,
+		row.Type,
+		formatFloat(row.Price),
+		row.Size,
+		formatOptionalFloat(row.HeadCircumference),
+		formatOptionalFloat(row.ChestCircumference),
+		formatOptionalFloat(row.WaistCircumference),
+		formatOptionalFloat(row.HipCircumference),
+		formatOptionalFloat(row.FootSize),
+		formatOptionalTime(row.ExpiryDate),
+		row.NutritionalInfo,
+		formatOptionalInt(row.WarrantyMonths),
+		row.Voltage,
+		row.Dimensions,
+		row.Material,
+		strconv.FormatInt(row.Version, 10),
+		row.CreatedAt.UTC().Format(time.RFC3339),
+		row.UpdatedAt.UTC().Format(time.RFC3339),
+	)
+
+	return w.writer.Write(w.record)
+}
+
+func (w *CSVWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return formatFloat(*value)
+}
+
+func formatOptionalInt(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+func formatOptionalTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
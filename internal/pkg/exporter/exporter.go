@@ -0,0 +1,117 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// Format - формат выгрузки каталога
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+// Writer пишет товары в поток по одному. Close дописывает буферы и служебные
+// данные формата, но не закрывает исходный io.Writer.
+type Writer interface {
+	Write(product *models.Product) error
+	Close() error
+}
+
+// NewWriter создает Writer для формата
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case CSV:
+		return NewCSVWriter(w)
+	case NDJSON:
+		return NewNDJSONWriter(w), nil
+	case Parquet:
+		return NewParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %q", format)
+	}
+}
+
+// ContentType - MIME-тип формата
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case NDJSON:
+		return "application/x-ndjson"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Row - товар с атрибутами, развернутыми в колонки. Имена колонок совпадают
+// с колонками CSV-импорта, поэтому выгрузку можно загрузить обратно.
+type Row struct {
+	ID     int64   `parquet:"id"`
+	Name   string  `parquet:"name"`
+	Weight float64 `parquet:"weight"`
+	Unit   string  `parquet:"unit"`
+	Color  string  `parquet:"color"`
+	Type   string  `parquet:"type"`
+	Price  float64 `parquet:"price"`
+
+	Size               string     `parquet:"size"`
+	HeadCircumference  *float64   `parquet:"head_circumference,optional"`
+	ChestCircumference *float64   `parquet:"chest_circumference,optional"`
+	WaistCircumference *float64   `parquet:"waist_circumference,optional"`
+	HipCircumference   *float64   `parquet:"hip_circumference,optional"`
+	FootSize           *float64   `parquet:"foot_size,optional"`
+	ExpiryDate         *time.Time `parquet:"expiry_date,optional,timestamp"`
+	NutritionalInfo    string     `parquet:"nutritional_info"`
+	WarrantyMonths     *int64     `parquet:"warranty_months,optional"`
+	Voltage            string     `parquet:"voltage"`
+	Dimensions         string     `parquet:"dimensions"`
+	Material           string     `parquet:"material"`
+
+	Version   int64     `parquet:"version"`
+	CreatedAt time.Time `parquet:"created_at,timestamp"`
+	UpdatedAt time.Time `parquet:"updated_at,timestamp"`
+}
+
+// NewRow разворачивает товар в строку выгрузки
+func NewRow(product *models.Product) Row {
+	row := Row{
+		ID:     int64(product.ID),
+		Name:   product.Name,
+		Weight: product.Weight,
+		Unit:   product.Unit,
+		Color:  product.Color,
+		Type:   string(product.Type),
+		Price:  product.Price,
+
+		Size:               product.Attributes.Size,
+		HeadCircumference:  product.Attributes.HeadCircumference,
+		ChestCircumference: product.Attributes.ChestCircumference,
+		WaistCircumference: product.Attributes.WaistCircumference,
+		HipCircumference:   product.Attributes.HipCircumference,
+		FootSize:           product.Attributes.FootSize,
+		ExpiryDate:         product.Attributes.ExpiryDate,
+		NutritionalInfo:    product.Attributes.NutritionalInfo,
+		Voltage:            product.Attributes.Voltage,
+		Dimensions:         product.Attributes.Dimensions,
+		Material:           product.Attributes.Material,
+
+		Version:   product.Version,
+		CreatedAt: product.CreatedAt,
+		UpdatedAt: product.UpdatedAt,
+	}
+	if product.Attributes.WarrantyMonths != nil {
+		months := int64(*product.Attributes.WarrantyMonths)
+		row.WarrantyMonths = &months
+	}
+
+	return row
+}
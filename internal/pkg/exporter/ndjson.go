@@ -0,0 +1,33 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// NDJSONWriter пишет товары в формате models.Product, по одному на строку;
+// этот же формат принимает NDJSON-импорт
+type NDJSONWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	buffer := bufio.NewWriter(w)
+
+	return &NDJSONWriter{
+		buffer:  buffer,
+		encoder: json.NewEncoder(buffer),
+	}
+}
+
+func (w *NDJSONWriter) Write(product *models.Product) error {
+	return w.encoder.Encode(product)
+}
+
+func (w *NDJSONWriter) Close() error {
+	return w.buffer.Flush()
+}
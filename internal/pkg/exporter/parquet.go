@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"io"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize - строк в группе; группа держится в памяти до записи
+const parquetRowGroupSize = 10000
+
+// ParquetWriter пишет Row группами строк; footer файла дописывается в Close
+type ParquetWriter struct {
+	writer *parquet.GenericWriter[Row]
+	rows   []Row
+}
+
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return &ParquetWriter{
+		writer: parquet.NewGenericWriter[Row](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		rows:   make([]Row, 1),
+	}
+}
+
+func (w *ParquetWriter) Write(product *models.Product) error {
+	w.rows[0] = NewRow(product)
+	_, err := w.writer.Write(w.rows)
+	return err
+}
+
+func (w *ParquetWriter) Close() error {
+	return w.writer.Close()
+}
@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// CSVReader читает CSV с заголовком. Колонки атрибутов можно писать как
+// с префиксом attributes., так и без него: size, attributes.size.
+type CSVReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+// columnSetters заполняют поле товара значением колонки
+var columnSetters = map[string]func(p *models.Product, value string) error{
+	"name":  func(p *models.Product, v string) error { p.Name = v; return nil },
+	"unit":  func(p *models.Product, v string) error { p.Unit = v; return nil },
+	"color": func(p *models.Product, v string) error { p.Color = v; return nil },
+	"type":  func(p *models.Product, v string) error { p.Type = models.ProductType(v); return nil },
+	"weight": func(p *models.Product, v string) error {
+		return parseFloat(v, &p.Weight)
+	},
+	"price": func(p *models.Product, v string) error {
+		return parseFloat(v, &p.Price)
+	},
+
+	"size":             func(p *models.Product, v string) error { p.Attributes.Size = v; return nil },
+	"nutritional_info": func(p *models.Product, v string) error { p.Attributes.NutritionalInfo = v; return nil },
+	"voltage":          func(p *models.Product, v string) error { p.Attributes.Voltage = v; return nil },
+	"dimensions":       func(p *models.Product, v string) error { p.Attributes.Dimensions = v; return nil },
+	"material":         func(p *models.Product, v string) error { p.Attributes.Material = v; return nil },
+	"head_circumference": func(p *models.Product, v string) error {
+		return parseOptionalFloat(v, &p.Attributes.HeadCircumference)
+	},
+	"chest_circumference": func(p *models.Product, v string) error {
+		return parseOptionalFloat(v, &p.Attributes.ChestCircumference)
+	},
+	"waist_circumference": func(p *models.Product, v string) error {
+		return parseOptionalFloat(v, &p.Attributes.WaistCircumference)
+	},
+	"hip_circumference": func(p *models.Product, v string) error {
+		return parseOptionalFloat(v, &p.Attributes.HipCircumference)
+	},
+	"foot_size": func(p *models.Product, v string) error {
+		return parseOptionalFloat(v, &p.Attributes.FootSize)
+	},
+	"warranty_months": func(p *models.Product, v string) error {
+		if v == "" {
+			return nil
+		}
+		months, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		p.Attributes.WarrantyMonths = &months
+		return nil
+	},
+	"expiry_date": func(p *models.Product, v string) error {
+		if v == "" {
+			return nil
+		}
+		// Дата без времени или RFC 3339
+		for _, layout := range []string{"2006-01-02", time.RFC3339} {
+			if date, err := time.Parse(layout, v); err == nil {
+				p.Attributes.ExpiryDate = &date
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", v)
+	},
+
+	// Служебные колонки выгрузки назначает система, при импорте они пропускаются
+	"id":         skipColumn,
+	"version":    skipColumn,
+	"created_at": skipColumn,
+	"updated_at": skipColumn,
+}
+
+func skipColumn(*models.Product, string) error {
+	return nil
+}
+
+// NewCSVReader читает заголовок и проверяет, что все колонки известны
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		column := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "attributes.")
+		if _, ok := columnSetters[column]; !ok {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		columns[i] = column
+	}
+
+	return &CSVReader{
+		reader:  reader,
+		columns: columns,
+	}, nil
+}
+
+func (r *CSVReader) Next() (int, *models.Product, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return parseErr.StartLine, nil, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return 0, nil, err
+	}
+	line, _ := r.reader.FieldPos(0)
+
+	if len(record) != len(r.columns) {
+		return line, nil, &RowError{
+			Line: line,
+			Err:  fmt.Errorf("expected %d columns, got %d", len(r.columns), len(record)),
+		}
+	}
+
+	product := &models.Product{}
+	for i, value := range record {
+		if err := columnSetters[r.columns[i]](product, strings.TrimSpace(value)); err != nil {
+			return line, nil, &RowError{Line: line, Err: fmt.Errorf("column %s: %w", r.columns[i], err)}
+		}
+	}
+
+	return line, product, nil
+}
+
+func parseFloat(value string, dst *float64) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*dst = parsed
+	return nil
+}
+
+func parseOptionalFloat(value string, dst **float64) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*dst = &parsed
+	return nil
+}
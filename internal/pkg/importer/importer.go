@@ -0,0 +1,63 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// Format - формат файла каталога
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+)
+
+// Reader построчно читает товары из файла. Next возвращает io.EOF в конце файла,
+// *RowError - если строку нельзя разобрать (чтение можно продолжать),
+// любую другую ошибку - если файл дальше читать нельзя.
+type Reader interface {
+	Next() (line int, product *models.Product, err error)
+}
+
+// RowError - ошибка разбора одной строки файла
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// NewReader создает Reader для формата
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case CSV:
+		return NewCSVReader(r)
+	case NDJSON:
+		return NewNDJSONReader(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported import format: %q", format)
+	}
+}
+
+// FormatFromPath определяет формат по расширению файла
+func FormatFromPath(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return CSV, true
+	case ".ndjson", ".jsonl":
+		return NDJSON, true
+	default:
+		return "", false
+	}
+}
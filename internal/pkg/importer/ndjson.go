@@ -0,0 +1,58 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// maxLineSize ограничивает длину строки NDJSON
+const maxLineSize = 1 << 20
+
+// NDJSONReader читает по одному товару в JSON на строку, в формате
+// models.Product; пустые строки пропускаются
+type NDJSONReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	return &NDJSONReader{
+		scanner: scanner,
+	}
+}
+
+func (r *NDJSONReader) Next() (int, *models.Product, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		var product models.Product
+		if err := decoder.Decode(&product); err != nil {
+			return r.line, nil, &RowError{Line: r.line, Err: fmt.Errorf("invalid json: %w", err)}
+		}
+		// ID и служебные поля назначает система
+		product.ID = 0
+		product.Version = 0
+
+		return r.line, &product, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return r.line, nil, fmt.Errorf("failed to read ndjson: %w", err)
+	}
+	return r.line, nil, io.EOF
+}
@@ -43,6 +43,17 @@ var (
 		Name: "kafka_messages_processed_total",
 		Help: "Total number of Kafka messages processed",
 	}, []string{"topic", "status"})
+
+	outboxMessagesRelayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_messages_relayed_total",
+		Help: "Total number of outbox messages relayed to Kafka",
+	}, []string{"status"})
+
+	// Метрики кеша
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Total number of cache lookups by cache kind and result",
+	}, []string{"cache", "result"})
 )
 
 func Init(port int) {
@@ -76,3 +87,17 @@ func RecordProductDeleted() {
 func RecordKafkaMessageProcessed(topic, status string) {
 	kafkaMessagesProcessed.WithLabelValues(topic, status).Inc()
 }
+
+func RecordOutboxMessageRelayed(status string) {
+	outboxMessagesRelayed.WithLabelValues(status).Inc()
+}
+
+// RecordCacheRequest учитывает обращение к кешу: cache - вид данных
+// (product, list, facets), hit - найдено ли значение
+func RecordCacheRequest(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
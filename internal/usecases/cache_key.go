@@ -0,0 +1,115 @@
+package usecases
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+)
+
+// filterKey приводит условия фильтра к каноническому виду: одинаковые по
+// смыслу фильтры (другой порядок типов, 10 и 10.0 в цене) дают один ключ.
+// Limit, Offset, курсоры и сортировка в ключ не входят.
+func filterKey(filter models.ProductFilter) string {
+	values := url.Values{}
+
+	setFloat := func(key string, v *float64) {
+		if v != nil {
+			values.Set(key, strconv.FormatFloat(*v, 'f', -1, 64))
+		}
+	}
+	setInt := func(key string, v *int) {
+		if v != nil {
+			values.Set(key, strconv.Itoa(*v))
+		}
+	}
+	setTime := func(key string, v *time.Time) {
+		if v != nil {
+			values.Set(key, v.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	setString := func(key, v string) {
+		if v != "" {
+			values.Set(key, v)
+		}
+	}
+
+	setFloat("min_price", filter.MinPrice)
+	setFloat("max_price", filter.MaxPrice)
+	setString("color", filter.Color)
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		slices.Sort(types)
+		values["type"] = slices.Compact(types)
+	}
+	setString("size", filter.Size)
+	setString("material", filter.Material)
+	setString("voltage", filter.Voltage)
+	setInt("min_warranty_months", filter.MinWarrantyMonths)
+	setInt("max_warranty_months", filter.MaxWarrantyMonths)
+	setFloat("min_foot_size", filter.MinFootSize)
+	setFloat("max_foot_size", filter.MaxFootSize)
+	setTime("expires_before", filter.ExpiresBefore)
+	setTime("expires_after", filter.ExpiresAfter)
+
+	// Encode сортирует ключи
+	return values.Encode()
+}
+
+// pageKey дополняет канонический фильтр параметрами страницы списка
+func pageKey(filter models.ProductFilter) string {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(filter.Limit))
+	values.Set("offset", strconv.Itoa(filter.Offset))
+	if filter.After != nil {
+		values.Set("after", cursorKey(filter.After))
+	}
+	if filter.Before != nil {
+		values.Set("before", cursorKey(filter.Before))
+	}
+	if len(filter.Sort) > 0 {
+		// Порядок ключей сортировки значим, поэтому он сохраняется
+		fields := make([]string, len(filter.Sort))
+		for i, field := range filter.Sort {
+			fields[i] = string(field.Key)
+			if field.Desc {
+				fields[i] = "-" + fields[i]
+			}
+		}
+		values["sort"] = fields
+	}
+
+	return filterKey(filter) + "&" + values.Encode()
+}
+
+func cursorKey(cursor *models.ProductCursor) string {
+	return cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "/" + strconv.Itoa(cursor.ID)
+}
+
+// listCacheKey, countCacheKey и facetsCacheKey включают поколение списков, чтобы
+// InvalidateLists сбрасывал все ключи разом
+func listCacheKey(generation int64, filter models.ProductFilter) string {
+	return hashKey(fmt.Sprintf("products:list:%d:", generation), pageKey(filter))
+}
+
+func countCacheKey(generation int64, filter models.ProductFilter, mode models.CountMode) string {
+	return hashKey(fmt.Sprintf("products:count:%d:", generation), filterKey(filter)+"&count="+string(mode))
+}
+
+func facetsCacheKey(generation int64, filter models.ProductFilter, buckets int) string {
+	return hashKey(fmt.Sprintf("products:facets:%d:", generation), filterKey(filter)+"&buckets="+strconv.Itoa(buckets))
+}
+
+// hashKey сокращает канонический фильтр до ключа фиксированной длины
+func hashKey(prefix, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return prefix + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,261 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+
+	"golang.org/x/time/rate"
+)
+
+// WarmMode - какие товары прогревать
+type WarmMode string
+
+const (
+	WarmAll      WarmMode = "all"       // весь каталог
+	WarmRecent   WarmMode = "recent"    // Limit последних обновленных
+	WarmMostRead WarmMode = "most_read" // Limit самых читаемых
+)
+
+var (
+	ErrWarmInProgress = errors.New("cache warm-up is already running")
+	ErrInvalidWarm    = errors.New("invalid cache warm-up options")
+)
+
+type WarmOptions struct {
+	Mode  WarmMode `json:"mode"`
+	Limit int      `json:"limit,omitempty"` // для recent и most_read
+}
+
+// WarmReport - итог прогрева
+type WarmReport struct {
+	WarmOptions
+	Written    int       `json:"written"` // отправлено в кеш, включая уже занятые ключи
+	Batches    int       `json:"batches"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// CacheWarmUseCase заполняет кеш карточек из БД после сброса Redis или
+// переключения на реплику, чтобы чтения не ушли в Postgres все разом.
+// Карточки пишутся пачками по batchSize не быстрее rate товаров в секунду и
+// только в пустые ключи: снимок БД, который читает прогрев, может отстать от
+// карточек, уже обновленных процессором.
+type CacheWarmUseCase struct {
+	repo      repositories.ProductRepository
+	cache     repositories.ProductCache
+	reads     repositories.ReadTracker
+	batchSize int
+	rate      int
+
+	mu      sync.Mutex
+	running bool
+	last    *WarmReport
+}
+
+// NewCacheWarmUseCase: reads может быть nil, тогда режим most_read недоступен;
+// rate <= 0 - без ограничения скорости
+func NewCacheWarmUseCase(
+	repo repositories.ProductRepository,
+	cache repositories.ProductCache,
+	reads repositories.ReadTracker,
+	batchSize int,
+	rate int,
+) *CacheWarmUseCase {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &CacheWarmUseCase{
+		repo:      repo,
+		cache:     cache,
+		reads:     reads,
+		batchSize: batchSize,
+		rate:      rate,
+	}
+}
+
+func (uc *CacheWarmUseCase) validate(opts WarmOptions) error {
+	switch opts.Mode {
+	case WarmAll:
+		return nil
+	case WarmRecent, WarmMostRead:
+		if opts.Limit <= 0 {
+			return fmt.Errorf("%w: limit is required for mode %s", ErrInvalidWarm, opts.Mode)
+		}
+		if opts.Mode == WarmMostRead && uc.reads == nil {
+			return fmt.Errorf("%w: read tracking is not configured", ErrInvalidWarm)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidWarm, opts.Mode)
+	}
+}
+
+// Warm прогревает кеш и возвращает отчет, в том числе при ошибке
+func (uc *CacheWarmUseCase) Warm(ctx context.Context, opts WarmOptions) (WarmReport, error) {
+	report := WarmReport{WarmOptions: opts, StartedAt: time.Now()}
+	if err := uc.validate(opts); err != nil {
+		return report, err
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, uc.batchSize)
+	if uc.rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(uc.rate), uc.batchSize)
+	}
+
+	batch := make(map[string]*models.Product, uc.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := limiter.WaitN(ctx, len(batch)); err != nil {
+			return err
+		}
+		if err := uc.cache.SetMany(ctx, batch); err != nil {
+			return err
+		}
+		report.Written += len(batch)
+		report.Batches++
+		clear(batch)
+		return nil
+	}
+	add := func(product *models.Product) error {
+		batch[fmt.Sprintf("product:%d", product.ID)] = product
+		if len(batch) >= uc.batchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	var err error
+	switch opts.Mode {
+	case WarmAll:
+		err = uc.warmAll(ctx, add)
+	case WarmRecent:
+		err = uc.warmRecent(ctx, opts.Limit, add)
+	case WarmMostRead:
+		err = uc.warmMostRead(ctx, opts.Limit, add)
+	}
+	if err == nil {
+		err = flush()
+	}
+
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
+
+// warmAll читает весь каталог keyset-страницами по batchSize. Каждая страница -
+// отдельный короткий запрос, поэтому ожидание лимита скорости между пачками
+// не держит открытыми транзакцию и курсор.
+func (uc *CacheWarmUseCase) warmAll(ctx context.Context, add func(*models.Product) error) error {
+	filter := models.ProductFilter{Limit: uc.batchSize}
+	for {
+		products, err := uc.repo.List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, product := range products {
+			if err := add(product); err != nil {
+				return err
+			}
+		}
+		if len(products) < filter.Limit {
+			return nil
+		}
+		position := models.CursorOf(products[len(products)-1])
+		filter.After = &position
+	}
+}
+
+// warmRecent читает последние обновленные товары страницами по batchSize
+func (uc *CacheWarmUseCase) warmRecent(ctx context.Context, limit int, add func(*models.Product) error) error {
+	filter := models.ProductFilter{
+		Sort: []models.SortField{{Key: models.SortUpdatedAt, Desc: true}},
+	}
+	for filter.Offset < limit {
+		filter.Limit = min(uc.batchSize, limit-filter.Offset)
+		products, err := uc.repo.List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, product := range products {
+			if err := add(product); err != nil {
+				return err
+			}
+		}
+		if len(products) < filter.Limit {
+			return nil
+		}
+		filter.Offset += len(products)
+	}
+	return nil
+}
+
+// warmMostRead читает самые читаемые товары по ID пачками по batchSize
+func (uc *CacheWarmUseCase) warmMostRead(ctx context.Context, limit int, add func(*models.Product) error) error {
+	ids, err := uc.reads.MostRead(ctx, limit)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(ids); start += uc.batchSize {
+		products, err := uc.repo.GetByIDs(ctx, ids[start:min(start+uc.batchSize, len(ids))])
+		if err != nil {
+			return err
+		}
+		for _, product := range products {
+			if err := add(product); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Start запускает прогрев в фоне; одновременно идет только один прогрев
+func (uc *CacheWarmUseCase) Start(ctx context.Context, opts WarmOptions) error {
+	if err := uc.validate(opts); err != nil {
+		return err
+	}
+
+	uc.mu.Lock()
+	if uc.running {
+		uc.mu.Unlock()
+		return ErrWarmInProgress
+	}
+	uc.running = true
+	uc.last = &WarmReport{WarmOptions: opts, StartedAt: time.Now()}
+	uc.mu.Unlock()
+
+	go func() {
+		report, err := uc.Warm(context.WithoutCancel(ctx), opts)
+		if err != nil {
+			fmt.Printf("Cache warm-up failed after %d products: %v\n", report.Written, err)
+		} else {
+			fmt.Printf("Cache warm-up finished: %d products in %d batches\n", report.Written, report.Batches)
+		}
+
+		uc.mu.Lock()
+		uc.running = false
+		uc.last = &report
+		uc.mu.Unlock()
+	}()
+
+	return nil
+}
+
+// Status возвращает, идет ли прогрев, и отчет последнего запуска (nil, если запусков не было)
+func (uc *CacheWarmUseCase) Status() (bool, *WarmReport) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.running, uc.last
+}
@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+	"github.com/FollG/kafka-with-go/internal/pkg/importer"
+	vld "github.com/FollG/kafka-with-go/internal/pkg/validator"
+)
+
+// ImportReject - строка файла, которая не прошла разбор, проверку или публикацию
+type ImportReject struct {
+	Line   int
+	Reason string
+}
+
+// ImportReport - итог импорта
+type ImportReport struct {
+	Rows     int
+	Imported int
+	Rejected int
+}
+
+// ImportUseCase загружает каталог из файла: каждая строка проверяется
+// валидатором и публикуется событием product_created
+type ImportUseCase struct {
+	repo      repositories.ProductRepository
+	producer  repositories.EventProducer
+	validator *vld.ProductValidator
+	batchSize int
+}
+
+func NewImportUseCase(
+	repo repositories.ProductRepository,
+	producer repositories.EventProducer,
+	validator *vld.ProductValidator,
+	batchSize int,
+) *ImportUseCase {
+	return &ImportUseCase{
+		repo:      repo,
+		producer:  producer,
+		validator: validator,
+		batchSize: max(batchSize, 1),
+	}
+}
+
+type importRow struct {
+	line    int
+	product *models.Product
+}
+
+// Import читает файл потоком и публикует принятые строки пачками по batchSize.
+// Отклоненные строки передаются в reject; ошибка reject или чтения файла
+// прерывает импорт, уже опубликованные пачки при этом остаются.
+func (uc *ImportUseCase) Import(ctx context.Context, reader importer.Reader, reject func(ImportReject) error) (ImportReport, error) {
+	var report ImportReport
+	rejectRow := func(line int, reason string) error {
+		report.Rejected++
+		return reject(ImportReject{Line: line, Reason: reason})
+	}
+
+	batch := make([]importRow, 0, uc.batchSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		line, product, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var rowErr *importer.RowError
+			if !errors.As(err, &rowErr) {
+				return report, err
+			}
+			report.Rows++
+			if err := rejectRow(rowErr.Line, rowErr.Err.Error()); err != nil {
+				return report, err
+			}
+			continue
+		}
+		report.Rows++
+
+		if err := uc.validator.Validate(product); err != nil {
+			if err := rejectRow(line, err.Error()); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		batch = append(batch, importRow{line: line, product: product})
+		if len(batch) == uc.batchSize {
+			if err := uc.publish(ctx, batch, &report, rejectRow); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := uc.publish(ctx, batch, &report, rejectRow); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// publish резервирует ID и отправляет пачку одним вызовом SendProductEvents.
+// Строки, которые продюсер не записал, попадают в отказы.
+func (uc *ImportUseCase) publish(ctx context.Context, rows []importRow, report *ImportReport, rejectRow func(int, string) error) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	ids, err := uc.repo.NextIDs(ctx, len(rows))
+	if err != nil {
+		return err
+	}
+
+	events := make([]*models.ProductEvent, len(rows))
+	for i, row := range rows {
+		row.product.ID = ids[i]
+		events[i] = &models.ProductEvent{
+			EventID:     generateEventID(),
+			EventType:   models.ProductCreated,
+			Timestamp:   time.Now(),
+			ProductID:   row.product.ID,
+			ProductData: row.product,
+			ProducerID:  "product-importer",
+		}
+	}
+
+	err = uc.producer.SendProductEvents(ctx, events)
+	if err == nil {
+		report.Imported += len(rows)
+		return nil
+	}
+
+	var publishErrors models.PublishErrors
+	if !errors.As(err, &publishErrors) || len(publishErrors) != len(rows) {
+		return err
+	}
+	for i, row := range rows {
+		if publishErrors[i] == nil {
+			report.Imported++
+			continue
+		}
+		if err := rejectRow(row.line, fmt.Sprintf("failed to publish: %v", publishErrors[i])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
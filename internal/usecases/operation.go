@@ -0,0 +1,22 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+)
+
+type OperationUseCase struct {
+	repo repositories.OperationRepository
+}
+
+func NewOperationUseCase(repo repositories.OperationRepository) *OperationUseCase {
+	return &OperationUseCase{
+		repo: repo,
+	}
+}
+
+func (uc *OperationUseCase) GetOperation(ctx context.Context, id string) (*models.Operation, error) {
+	return uc.repo.GetByID(ctx, id)
+}
@@ -0,0 +1,229 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/models"
+	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+	"github.com/FollG/kafka-with-go/internal/pkg/logger"
+	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
+)
+
+// OutboxRelay переносит события из product_outbox в Kafka
+type OutboxRelay struct {
+	outbox        repositories.OutboxRepository
+	eventProducer repositories.EventProducer
+	batchSize     int
+	pollInterval  time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	retention     time.Duration
+	lease         time.Duration
+}
+
+// defaultOutboxLease - сколько захваченная пачка недоступна другим relay'ям
+const defaultOutboxLease = 30 * time.Second
+
+func NewOutboxRelay(
+	outbox repositories.OutboxRepository,
+	eventProducer repositories.EventProducer,
+	batchSize int,
+	pollInterval, minBackoff, maxBackoff, retention time.Duration,
+) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:        outbox,
+		eventProducer: eventProducer,
+		batchSize:     batchSize,
+		pollInterval:  pollInterval,
+		minBackoff:    minBackoff,
+		maxBackoff:    maxBackoff,
+		retention:     retention,
+		lease:         defaultOutboxLease,
+	}
+}
+
+// SetLease задает, на сколько relay захватывает пачку. Lease должен быть
+// больше времени публикации пачки, иначе ее может повторно отправить другой relay.
+func (r *OutboxRelay) SetLease(lease time.Duration) {
+	if lease > 0 {
+		r.lease = lease
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-cleanup.C:
+			r.cleanup(ctx)
+		case <-ticker.C:
+			// Пока выбирается полная пачка, продолжаем без ожидания тикера
+			for {
+				relayed, err := r.relayBatch(ctx)
+				if err != nil {
+					logger.Error(ctx, "failed to relay outbox batch", "error", err)
+					break
+				}
+				if relayed < r.batchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// errEarlierEventFailed - причина отложить сообщение, когда более раннее
+// сообщение того же товара не отправлено
+var errEarlierEventFailed = errors.New("earlier event of the same product failed to relay")
+
+// relayBatch публикует пачку из outbox одним вызовом SendProductEvents
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	messages, err := r.outbox.FetchPending(ctx, r.batchSize, r.lease)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	// Ключи, по которым сообщение не удалось разобрать или отправить:
+	// следующие события этих товаров ждут повтора, иначе нарушится порядок
+	blocked := make(map[string]bool)
+
+	pending := make([]*models.OutboxMessage, 0, len(messages))
+	events := make([]*models.ProductEvent, 0, len(messages))
+	for _, msg := range messages {
+		if blocked[msg.AggregateKey] {
+			if err := r.fail(ctx, msg, errEarlierEventFailed); err != nil {
+				return len(messages), err
+			}
+			continue
+		}
+
+		var event models.ProductEvent
+		if err := json.Unmarshal(msg.Payload, &event); err != nil {
+			blocked[msg.AggregateKey] = true
+			if err := r.fail(ctx, msg, err); err != nil {
+				return len(messages), err
+			}
+			continue
+		}
+
+		pending = append(pending, msg)
+		events = append(events, &event)
+	}
+
+	// Writer делит сообщения на пачки и может записать их частично, поэтому
+	// результат проверяется по каждому сообщению
+	publishErrors := make([]error, len(pending))
+	if err := r.eventProducer.SendProductEvents(ctx, events); err != nil {
+		var perrs models.PublishErrors
+		if errors.As(err, &perrs) && len(perrs) == len(pending) {
+			copy(publishErrors, perrs)
+		} else {
+			for i := range publishErrors {
+				publishErrors[i] = err
+			}
+		}
+	}
+
+	// После первой ошибки по ключу следующие сообщения этого ключа тоже
+	// откладываются, даже если записались: повтор придет после упавшего,
+	// дубль отбросит processed_events, а опередившее событие - проверка Sequence
+	sent := make([]int64, 0, len(pending))
+	for i, msg := range pending {
+		cause := publishErrors[i]
+		if cause == nil && blocked[msg.AggregateKey] {
+			cause = errEarlierEventFailed
+		}
+		if cause != nil {
+			blocked[msg.AggregateKey] = true
+			if err := r.fail(ctx, msg, cause); err != nil {
+				return len(messages), err
+			}
+			continue
+		}
+		sent = append(sent, msg.ID)
+	}
+
+	if err := r.outbox.MarkSent(ctx, sent...); err != nil {
+		return len(messages), err
+	}
+	for range sent {
+		metrics.RecordOutboxMessageRelayed("sent")
+	}
+
+	return len(messages), nil
+}
+
+// fail откладывает повтор сообщения с экспоненциальной задержкой
+func (r *OutboxRelay) fail(ctx context.Context, msg *models.OutboxMessage, cause error) error {
+	metrics.RecordOutboxMessageRelayed("failed")
+
+	nextAttemptAt := time.Now().Add(r.backoff(msg.Attempts))
+	logger.Error(ctx, "failed to relay outbox message",
+		"event_id", msg.EventID,
+		"attempts", msg.Attempts+1,
+		"next_attempt_at", nextAttemptAt,
+		"error", cause,
+	)
+
+	return r.outbox.MarkFailed(ctx, msg.ID, cause.Error(), nextAttemptAt)
+}
+
+// backoff растет экспоненциально от minBackoff и ограничен maxBackoff
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.minBackoff
+	for i := 0; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
+
+func (r *OutboxRelay) cleanup(ctx context.Context) {
+	deleted, err := r.outbox.DeleteSentBefore(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		logger.Error(ctx, "failed to clean up outbox", "error", err)
+		return
+	}
+	if deleted > 0 {
+		logger.Info(ctx, "outbox cleaned up", "deleted", deleted)
+	}
+}
+
+// OutboxPublisher - EventProducer поверх outbox: так API публикует события
+// через relay, не подключаясь к Kafka напрямую
+type OutboxPublisher struct {
+	outbox repositories.OutboxRepository
+}
+
+func NewOutboxPublisher(outbox repositories.OutboxRepository) *OutboxPublisher {
+	return &OutboxPublisher{
+		outbox: outbox,
+	}
+}
+
+func (p *OutboxPublisher) SendProductEvent(ctx context.Context, event *models.ProductEvent) error {
+	return p.outbox.Add(ctx, event)
+}
+
+func (p *OutboxPublisher) SendProductEvents(ctx context.Context, events []*models.ProductEvent) error {
+	return p.outbox.AddBatch(ctx, events)
+}
+
+func (p *OutboxPublisher) Close() error {
+	return nil
+}
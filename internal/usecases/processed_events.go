@@ -0,0 +1,64 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+	"github.com/FollG/kafka-with-go/internal/pkg/logger"
+)
+
+// processedCleanupBatch - сколько отметок удаляется одним запросом
+const processedCleanupBatch = 10000
+
+// ProcessedEventsCleaner периодически удаляет старые отметки processed_events.
+// Окно хранения должно быть больше retention топика вместе со всеми
+// retry-задержками: пока событие может быть доставлено повторно, его отметка нужна.
+type ProcessedEventsCleaner struct {
+	productRepo repositories.ProductRepository
+	retention   time.Duration
+	interval    time.Duration
+}
+
+func NewProcessedEventsCleaner(productRepo repositories.ProductRepository, retention, interval time.Duration) *ProcessedEventsCleaner {
+	return &ProcessedEventsCleaner{
+		productRepo: productRepo,
+		retention:   retention,
+		interval:    interval,
+	}
+}
+
+func (c *ProcessedEventsCleaner) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			c.cleanup(ctx)
+		}
+	}
+}
+
+func (c *ProcessedEventsCleaner) cleanup(ctx context.Context) {
+	before := time.Now().Add(-c.retention)
+
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := c.productRepo.DeleteProcessedBefore(ctx, before, processedCleanupBatch)
+		if err != nil {
+			logger.Error(ctx, "failed to clean up processed events", "error", err)
+			break
+		}
+		total += deleted
+		if deleted < processedCleanupBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info(ctx, "processed events cleaned up", "deleted", total)
+	}
+}
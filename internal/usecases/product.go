@@ -2,75 +2,147 @@ package usecases
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/FollG/kafka-with-go/internal/domain/models"
 	"github.com/FollG/kafka-with-go/internal/domain/repositories"
+	"github.com/FollG/kafka-with-go/internal/pkg/metrics"
 	vld "github.com/FollG/kafka-with-go/internal/pkg/validator"
+
+	"golang.org/x/sync/singleflight"
 )
 
 type ProductUseCase struct {
-	repo          repositories.ProductRepository
-	cache         repositories.ProductCache
-	eventProducer repositories.EventProducer
-	validator     *vld.ProductValidator
+	repo      repositories.ProductRepository
+	cache     repositories.ProductCache
+	outbox    repositories.OutboxRepository
+	validator *vld.ProductValidator
+
+	loads singleflight.Group // загрузки карточек из БД по ключу кеша
+	reads repositories.ReadTracker
 }
 
+// revalidateTimeout ограничивает фоновое обновление устаревшей карточки
+const revalidateTimeout = 5 * time.Second
+
 func NewProductUseCase(
 	repo repositories.ProductRepository,
 	cache repositories.ProductCache,
-	eventProducer repositories.EventProducer,
+	outbox repositories.OutboxRepository,
 	validator *vld.ProductValidator,
 ) *ProductUseCase {
 	return &ProductUseCase{
-		repo:          repo,
-		cache:         cache,
-		eventProducer: eventProducer,
-		validator:     validator,
+		repo:      repo,
+		cache:     cache,
+		outbox:    outbox,
+		validator: validator,
 	}
 }
 
-func (uc *ProductUseCase) CreateProduct(ctx context.Context, product *models.Product) error {
+func (uc *ProductUseCase) CreateProduct(ctx context.Context, product *models.Product) (*models.Operation, error) {
 	if err := uc.validator.Validate(product); err != nil {
-		return err
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidProduct, err)
+	}
+
+	// ID резервируется сразу, чтобы клиент мог запросить товар после 202,
+	// а все события товара шли в Kafka с одним ключом
+	id, err := uc.repo.NextID(ctx)
+	if err != nil {
+		return nil, err
 	}
+	product.ID = id
 
 	event := &models.ProductEvent{
 		EventID:     generateEventID(),
 		EventType:   models.ProductCreated,
 		Timestamp:   time.Now(),
+		ProductID:   product.ID,
 		ProductData: product,
 		ProducerID:  "product-api",
-		Sequence:    time.Now().UnixNano(),
 	}
 
-	if err := uc.eventProducer.SendProductEvent(ctx, event); err != nil {
-		return err
-	}
+	return uc.enqueue(ctx, event)
+}
 
-	return nil
+// SetReadTracker включает учет прочтений карточек для прогрева кеша
+func (uc *ProductUseCase) SetReadTracker(reads repositories.ReadTracker) {
+	uc.reads = reads
 }
 
+// GetProduct читает карточку из кеша. Одновременные промахи по одному товару
+// объединяются в один запрос к БД; устаревшая карточка отдается сразу и
+// обновляется в фоне; отсутствие товара тоже кешируется.
 func (uc *ProductUseCase) GetProduct(ctx context.Context, id int) (*models.Product, error) {
-	// Пытаемся получить из кеша
+	product, err := uc.getProduct(ctx, id)
+	if err == nil && uc.reads != nil {
+		uc.reads.TrackRead(id)
+	}
+	return product, err
+}
+
+func (uc *ProductUseCase) getProduct(ctx context.Context, id int) (*models.Product, error) {
 	cacheKey := fmt.Sprintf("product:%d", id)
 	if cached, err := uc.cache.Get(ctx, cacheKey); err == nil && cached != nil {
-		return cached, nil
+		metrics.RecordCacheRequest("product", true)
+		if cached.NotFound {
+			return nil, models.ErrProductNotFound
+		}
+		if cached.Stale {
+			uc.revalidateProduct(ctx, id)
+		}
+		return cached.Product, nil
 	}
+	metrics.RecordCacheRequest("product", false)
 
-	// Если нет в кеше, идем в базу
-	product, err := uc.repo.GetByID(ctx, id)
+	// Контекст первого запроса не должен отменять загрузку для остальных
+	result, err, _ := uc.loads.Do(cacheKey, func() (interface{}, error) {
+		return uc.loadProduct(context.WithoutCancel(ctx), id)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	return result.(*models.Product), nil
+}
+
+// revalidateProduct обновляет устаревшую карточку в фоне; одновременные
+// обновления одного товара объединяются с загрузками GetProduct
+func (uc *ProductUseCase) revalidateProduct(ctx context.Context, id int) {
+	bgCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), revalidateTimeout)
+	ch := uc.loads.DoChan(fmt.Sprintf("product:%d", id), func() (interface{}, error) {
+		return uc.loadProduct(bgCtx, id)
+	})
+	go func() {
+		defer cancel()
+		if res := <-ch; res.Err != nil && !errors.Is(res.Err, models.ErrProductNotFound) {
+			fmt.Printf("Failed to revalidate product %d: %v\n", id, res.Err)
+		}
+	}()
+}
+
+// loadProduct читает товар из БД и кладет результат в кеш, в том числе отсутствие
+func (uc *ProductUseCase) loadProduct(ctx context.Context, id int) (*models.Product, error) {
+	cacheKey := fmt.Sprintf("product:%d", id)
+
+	product, err := uc.repo.GetByID(ctx, id)
 	// На случай, если реализация репозитория вернет (nil, nil)
-	if product == nil {
-		return nil, models.ErrProductNotFound
+	if err == nil && product == nil {
+		err = models.ErrProductNotFound
+	}
+	if errors.Is(err, models.ErrProductNotFound) {
+		if err := uc.cache.SetNotFound(ctx, cacheKey); err != nil {
+			fmt.Printf("Failed to cache missing product: %v\n", err)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Сохраняем в кеш
 	if err := uc.cache.Set(ctx, cacheKey, product); err != nil {
 		// Логируем ошибку, но не прерываем выполнение
 		fmt.Printf("Failed to cache product: %v\n", err)
@@ -79,10 +151,16 @@ func (uc *ProductUseCase) GetProduct(ctx context.Context, id int) (*models.Produ
 	return product, nil
 }
 
-func (uc *ProductUseCase) UpdateProduct(ctx context.Context, product *models.Product) error {
+// UpdateProduct ставит обновление в очередь. expectedVersion - версия из If-Match
+// (0 - без проверки); процессор еще раз сверит ее при применении события.
+func (uc *ProductUseCase) UpdateProduct(ctx context.Context, product *models.Product, expectedVersion int64) (*models.Operation, error) {
 	// Валидация
 	if err := uc.validator.Validate(product); err != nil {
-		return err
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidProduct, err)
+	}
+
+	if err := uc.checkVersion(ctx, product.ID, expectedVersion); err != nil {
+		return nil, err
 	}
 
 	// Создаем событие для Kafka
@@ -93,11 +171,12 @@ func (uc *ProductUseCase) UpdateProduct(ctx context.Context, product *models.Pro
 		ProductID:   product.ID,
 		ProductData: product,
 		ProducerID:  "product-api",
-		Sequence:    time.Now().UnixNano(),
+		Version:     expectedVersion,
 	}
 
-	if err := uc.eventProducer.SendProductEvent(ctx, event); err != nil {
-		return err
+	operation, err := uc.enqueue(ctx, event)
+	if err != nil {
+		return nil, err
 	}
 
 	cacheKey := fmt.Sprintf("product:%d", product.ID)
@@ -105,21 +184,70 @@ func (uc *ProductUseCase) UpdateProduct(ctx context.Context, product *models.Pro
 		fmt.Printf("Failed to invalidate cache: %v\n", err)
 	}
 
-	return nil
+	return operation, nil
+}
+
+// PatchProduct ставит в очередь частичное изменение товара. Патч сразу
+// проверяется на текущей версии, но процессор применит его заново к строке,
+// актуальной на момент обработки события, и еще раз проверит результат.
+func (uc *ProductUseCase) PatchProduct(ctx context.Context, id int, format models.PatchFormat, patch []byte, expectedVersion int64) (*models.Operation, error) {
+	current, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return nil, models.ErrVersionConflict
+	}
+
+	patched, err := current.ApplyPatch(format, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.validator.Validate(patched); err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidProduct, err)
+	}
+
+	event := &models.ProductEvent{
+		EventID:     generateEventID(),
+		EventType:   models.ProductPatched,
+		Timestamp:   time.Now(),
+		ProductID:   id,
+		ProducerID:  "product-api",
+		Version:     expectedVersion,
+		Patch:       patch,
+		PatchFormat: format,
+	}
+
+	operation, err := uc.enqueue(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("product:%d", id)
+	if err := uc.cache.Delete(ctx, cacheKey); err != nil {
+		fmt.Printf("Failed to invalidate cache: %v\n", err)
+	}
+
+	return operation, nil
 }
 
-func (uc *ProductUseCase) DeleteProduct(ctx context.Context, id int) error {
+func (uc *ProductUseCase) DeleteProduct(ctx context.Context, id int, expectedVersion int64) (*models.Operation, error) {
+	if err := uc.checkVersion(ctx, id, expectedVersion); err != nil {
+		return nil, err
+	}
+
 	event := &models.ProductEvent{
 		EventID:    generateEventID(),
 		EventType:  models.ProductDeleted,
 		Timestamp:  time.Now(),
 		ProductID:  id,
 		ProducerID: "product-api",
-		Sequence:   time.Now().UnixNano(),
+		Version:    expectedVersion,
 	}
 
-	if err := uc.eventProducer.SendProductEvent(ctx, event); err != nil {
-		return err
+	operation, err := uc.enqueue(ctx, event)
+	if err != nil {
+		return nil, err
 	}
 
 	cacheKey := fmt.Sprintf("product:%d", id)
@@ -127,14 +255,313 @@ func (uc *ProductUseCase) DeleteProduct(ctx context.Context, id int) error {
 		fmt.Printf("Failed to invalidate cache: %v\n", err)
 	}
 
+	return operation, nil
+}
+
+// ExportProducts передает в fn все товары по фильтру без учета пагинации
+func (uc *ProductUseCase) ExportProducts(ctx context.Context, filter models.ProductFilter, fn func(*models.Product) error) error {
+	return uc.repo.Stream(ctx, filter, fn)
+}
+
+// BatchProducts принимает пачку изменений: каждый элемент проверяется отдельно,
+// а принятые сохраняются в operations и outbox одной транзакцией.
+// Результаты возвращаются в порядке элементов.
+func (uc *ProductUseCase) BatchProducts(ctx context.Context, items []models.BatchItem) ([]models.BatchItemResult, error) {
+	results := make([]models.BatchItemResult, len(items))
+
+	for i, item := range items {
+		results[i].ProductID = item.ProductID
+		results[i].Err = uc.checkBatchItem(item)
+	}
+	if err := uc.checkBatchVersions(ctx, items, results); err != nil {
+		return nil, err
+	}
+
+	creates := 0
+	for i, item := range items {
+		if results[i].Err == nil && item.Action == models.BatchCreate {
+			creates++
+		}
+	}
+
+	var ids []int
+	if creates > 0 {
+		var err error
+		if ids, err = uc.repo.NextIDs(ctx, creates); err != nil {
+			return nil, err
+		}
+	}
+
+	events := make([]*models.ProductEvent, 0, len(items))
+	accepted := make([]int, 0, len(items))
+	for i, item := range items {
+		if results[i].Err != nil {
+			continue
+		}
+
+		event := &models.ProductEvent{
+			EventID:    generateEventID(),
+			Timestamp:  time.Now(),
+			ProductID:  item.ProductID,
+			ProducerID: "product-api",
+			Version:    item.Version,
+		}
+		switch item.Action {
+		case models.BatchCreate:
+			item.Product.ID, ids = ids[0], ids[1:]
+			event.EventType = models.ProductCreated
+			event.ProductID = item.Product.ID
+			event.ProductData = item.Product
+			event.Version = 0
+		case models.BatchUpdate:
+			item.Product.ID = item.ProductID
+			event.EventType = models.ProductUpdated
+			event.ProductData = item.Product
+		case models.BatchDelete:
+			event.EventType = models.ProductDeleted
+		}
+
+		results[i].ProductID = event.ProductID
+		events = append(events, event)
+		accepted = append(accepted, i)
+	}
+
+	operations, err := uc.enqueueBatch(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range accepted {
+		results[i].Operation = operations[j]
+		if items[i].Action == models.BatchCreate {
+			continue
+		}
+		cacheKey := fmt.Sprintf("product:%d", results[i].ProductID)
+		if err := uc.cache.Delete(ctx, cacheKey); err != nil {
+			fmt.Printf("Failed to invalidate cache: %v\n", err)
+		}
+	}
+
+	return results, nil
+}
+
+// checkBatchItem проверяет элемент пакетного запроса так же, как одиночные
+// CreateProduct, UpdateProduct и DeleteProduct; версии проверяет checkBatchVersions
+func (uc *ProductUseCase) checkBatchItem(item models.BatchItem) error {
+	switch item.Action {
+	case models.BatchCreate, models.BatchUpdate:
+		if item.Product == nil {
+			return fmt.Errorf("%w: product is required for %s", models.ErrInvalidProduct, item.Action)
+		}
+		if err := uc.validator.Validate(item.Product); err != nil {
+			return fmt.Errorf("%w: %v", models.ErrInvalidProduct, err)
+		}
+	case models.BatchDelete:
+	default:
+		return fmt.Errorf("%w: unknown action %q", models.ErrInvalidProduct, item.Action)
+	}
+
+	if item.Action == models.BatchCreate {
+		return nil
+	}
+	if item.ProductID <= 0 {
+		return fmt.Errorf("%w: product id is required for %s", models.ErrInvalidProduct, item.Action)
+	}
+
 	return nil
 }
 
+// checkBatchVersions сверяет If-Match принятых элементов с текущими версиями,
+// загруженными одним запросом
+func (uc *ProductUseCase) checkBatchVersions(ctx context.Context, items []models.BatchItem, results []models.BatchItemResult) error {
+	var ids []int
+	for i, item := range items {
+		if results[i].Err == nil && item.Action != models.BatchCreate && item.Version != 0 {
+			ids = append(ids, item.ProductID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	products, err := uc.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	versions := make(map[int]int64, len(products))
+	for _, product := range products {
+		versions[product.ID] = product.Version
+	}
+
+	for i, item := range items {
+		if results[i].Err != nil || item.Action == models.BatchCreate || item.Version == 0 {
+			continue
+		}
+		version, ok := versions[item.ProductID]
+		switch {
+		case !ok:
+			results[i].Err = models.ErrProductNotFound
+		case version != item.Version:
+			results[i].Err = models.ErrVersionConflict
+		}
+	}
+
+	return nil
+}
+
+// ListProducts отдает страницу списка из кеша, если она там есть. Ключ строится
+// из канонического вида фильтра и поколения списков, которое Consumer
+// увеличивает при каждом изменении товаров.
 func (uc *ProductUseCase) ListProducts(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error) {
-	// для списков также можно использовать кеш, но это сложнее из-за вариативности фильтров
-	return uc.repo.List(ctx, filter)
+	generation, err := uc.cache.ListGeneration(ctx)
+	if err != nil {
+		// Без поколения нельзя отличить актуальную страницу от устаревшей
+		fmt.Printf("Failed to get list generation: %v\n", err)
+		return uc.repo.List(ctx, filter)
+	}
+
+	cacheKey := listCacheKey(generation, filter)
+	if cached, err := uc.cache.GetList(ctx, cacheKey); err == nil && cached != nil {
+		metrics.RecordCacheRequest("list", true)
+		return cached, nil
+	}
+	metrics.RecordCacheRequest("list", false)
+
+	products, err := uc.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if products == nil {
+		// Пустая страница тоже кешируется: nil из GetList означает промах
+		products = []*models.Product{}
+	}
+
+	if err := uc.cache.SetList(ctx, cacheKey, products); err != nil {
+		fmt.Printf("Failed to cache products list: %v\n", err)
+	}
+
+	return products, nil
+}
+
+// GetFacets возвращает агрегаты каталога по фильтру. Результат кешируется по
+// каноническому виду фильтра и поколению списков, как и страницы списка.
+func (uc *ProductUseCase) GetFacets(ctx context.Context, filter models.ProductFilter, buckets int) (*models.ProductFacets, error) {
+	generation, err := uc.cache.ListGeneration(ctx)
+	if err != nil {
+		fmt.Printf("Failed to get list generation: %v\n", err)
+		return uc.repo.Facets(ctx, filter, buckets)
+	}
+
+	cacheKey := facetsCacheKey(generation, filter, buckets)
+	if cached, err := uc.cache.GetFacets(ctx, cacheKey); err == nil && cached != nil {
+		metrics.RecordCacheRequest("facets", true)
+		return cached, nil
+	}
+	metrics.RecordCacheRequest("facets", false)
+
+	facets, err := uc.repo.Facets(ctx, filter, buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.cache.SetFacets(ctx, cacheKey, facets); err != nil {
+		fmt.Printf("Failed to cache facets: %v\n", err)
+	}
+
+	return facets, nil
+}
+
+// SearchProducts ищет товары по тексту с условиями фильтра
+func (uc *ProductUseCase) SearchProducts(ctx context.Context, query string, filter models.ProductFilter) ([]*models.SearchResult, int, error) {
+	return uc.repo.Search(ctx, query, filter)
+}
+
+// CountProducts возвращает общее количество товаров по фильтру точно или оценкой
+func (uc *ProductUseCase) CountProducts(ctx context.Context, filter models.ProductFilter, mode models.CountMode) (int, error) {
+	count := uc.repo.Count
+	if mode == models.CountEstimated {
+		count = uc.repo.EstimateCount
+	}
+
+	// Итог кешируется под тем же поколением, что и страницы списка, поэтому
+	// COUNT(*) выполняется один раз на фильтр до ближайшего изменения товаров
+	generation, err := uc.cache.ListGeneration(ctx)
+	if err != nil {
+		fmt.Printf("Failed to get list generation: %v\n", err)
+		return count(ctx, filter)
+	}
+
+	cacheKey := countCacheKey(generation, filter, mode)
+	if cached, ok, err := uc.cache.GetCount(ctx, cacheKey); err == nil && ok {
+		metrics.RecordCacheRequest("count", true)
+		return cached, nil
+	}
+	metrics.RecordCacheRequest("count", false)
+
+	total, err := count(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := uc.cache.SetCount(ctx, cacheKey, total); err != nil {
+		fmt.Printf("Failed to cache products count: %v\n", err)
+	}
+
+	return total, nil
+}
+
+// checkVersion заранее отклоняет запрос с устаревшим If-Match, чтобы клиент
+// получил 412 сразу, а не через статус операции
+func (uc *ProductUseCase) checkVersion(ctx context.Context, id int, expectedVersion int64) error {
+	if expectedVersion == 0 {
+		return nil
+	}
+
+	current, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if current.Version != expectedVersion {
+		return models.ErrVersionConflict
+	}
+
+	return nil
+}
+
+// enqueue заводит операцию в статусе pending и сохраняет событие в outbox,
+// откуда его отправит в Kafka relay
+func (uc *ProductUseCase) enqueue(ctx context.Context, event *models.ProductEvent) (*models.Operation, error) {
+	operations, err := uc.enqueueBatch(ctx, []*models.ProductEvent{event})
+	if err != nil {
+		return nil, err
+	}
+	return operations[0], nil
+}
+
+// enqueueBatch - пакетный вариант enqueue. Операции и события пишутся одной
+// транзакцией, поэтому при сбое не остается операций без событий.
+func (uc *ProductUseCase) enqueueBatch(ctx context.Context, events []*models.ProductEvent) ([]*models.Operation, error) {
+	operations := make([]*models.Operation, len(events))
+	for i, event := range events {
+		operations[i] = &models.Operation{
+			ID:        event.EventID,
+			EventType: event.EventType,
+			ProductID: event.ProductID,
+			Status:    models.OperationPending,
+		}
+	}
+
+	if err := uc.outbox.AddWithOperations(ctx, events, operations); err != nil {
+		return nil, err
+	}
+
+	return operations, nil
 }
 
+// generateEventID добавляет к времени случайный суффикс: по EventID процессор
+// отбрасывает повторы, поэтому совпадение ID у разных реплик API недопустимо
 func generateEventID() string {
-	return fmt.Sprintf("event-%d", time.Now().UnixNano())
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("event-%d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
 }
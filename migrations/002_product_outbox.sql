@@ -0,0 +1,16 @@
+CREATE TABLE product_outbox (
+                                id BIGSERIAL PRIMARY KEY,
+                                event_id VARCHAR(64) NOT NULL UNIQUE,
+                                event_type VARCHAR(32) NOT NULL,
+                                aggregate_key VARCHAR(64) NOT NULL,
+                                payload JSONB NOT NULL,
+                                attempts INT NOT NULL DEFAULT 0,
+                                last_error TEXT,
+                                next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_product_outbox_pending ON product_outbox(next_attempt_at, id) WHERE sent_at IS NULL;
+CREATE INDEX idx_product_outbox_key ON product_outbox(aggregate_key, id) WHERE sent_at IS NULL;
+CREATE INDEX idx_product_outbox_sent_at ON product_outbox(sent_at) WHERE sent_at IS NOT NULL;
@@ -0,0 +1,7 @@
+CREATE TABLE processed_events (
+                                  event_id VARCHAR(64) PRIMARY KEY,
+                                  event_type VARCHAR(32) NOT NULL,
+                                  processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
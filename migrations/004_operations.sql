@@ -0,0 +1,11 @@
+CREATE TABLE operations (
+                            id VARCHAR(64) PRIMARY KEY,
+                            event_type VARCHAR(32) NOT NULL,
+                            product_id BIGINT NOT NULL DEFAULT 0,
+                            status VARCHAR(16) NOT NULL DEFAULT 'pending',
+                            error TEXT,
+                            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_operations_created_at ON operations(created_at);
@@ -0,0 +1,5 @@
+CREATE TABLE product_sequences (
+                                   product_id BIGINT PRIMARY KEY,
+                                   last_sequence BIGINT NOT NULL,
+                                   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
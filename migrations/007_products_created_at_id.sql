@@ -0,0 +1,3 @@
+-- Индекс под keyset-пагинацию списка: ORDER BY created_at DESC, id DESC
+CREATE INDEX idx_products_created_at_id ON products(created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_products_created_at;
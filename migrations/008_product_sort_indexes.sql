@@ -0,0 +1,6 @@
+-- Индексы под сортировку списка (параметр sort); price уже проиндексирован
+CREATE INDEX idx_products_name ON products(name);
+CREATE INDEX idx_products_weight ON products(weight);
+CREATE INDEX idx_products_updated_at ON products(updated_at);
+CREATE INDEX idx_products_warranty_months ON products(((attributes->>'warranty_months')::int));
+CREATE INDEX idx_products_foot_size ON products(((attributes->>'foot_size')::numeric));
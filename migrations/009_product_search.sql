@@ -0,0 +1,27 @@
+-- Полнотекстовый поиск (GET /api/v1/products/search): вектор по названию, цвету,
+-- материалу и пищевой ценности, поддерживается триггером
+ALTER TABLE products ADD COLUMN search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION update_products_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector =
+        setweight(to_tsvector('russian', COALESCE(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('russian', COALESCE(NEW.color, '')), 'B') ||
+        setweight(to_tsvector('russian', COALESCE(NEW.attributes->>'material', '')), 'B') ||
+        setweight(to_tsvector('russian', COALESCE(NEW.attributes->>'nutritional_info', '')), 'C');
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER update_products_search_vector
+    BEFORE INSERT OR UPDATE OF name, color, attributes ON products
+    FOR EACH ROW
+    EXECUTE FUNCTION update_products_search_vector();
+
+-- Заполняем вектор для существующих товаров через триггер (updated_at при этом не меняется)
+ALTER TABLE products DISABLE TRIGGER update_products_updated_at;
+UPDATE products SET name = name;
+ALTER TABLE products ENABLE TRIGGER update_products_updated_at;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN(search_vector);
@@ -0,0 +1,12 @@
+-- Sequence событий теперь выдает outbox (id строки product_outbox) вместо
+-- часов реплик API. Последовательность сдвигается выше прежних значений
+-- (наносекунды UNIX-времени) с запасом на расхождение часов, иначе новые
+-- события товара считались бы устаревшими по product_sequences.
+SELECT setval(
+    pg_get_serial_sequence('product_outbox', 'id'),
+    GREATEST(
+        (SELECT COALESCE(MAX(last_sequence), 0) FROM product_sequences),
+        (EXTRACT(EPOCH FROM NOW() + INTERVAL '1 day') * 1000000000)::BIGINT,
+        (SELECT COALESCE(MAX(id), 1) FROM product_outbox)
+    )
+);
@@ -0,0 +1,6 @@
+-- Индексы под сортировку по обхватам (параметр sort): без них сортировка и
+-- keyset-страницы по этим ключам сортируют всю таблицу
+CREATE INDEX idx_products_head_circumference ON products(((attributes->>'head_circumference')::numeric));
+CREATE INDEX idx_products_chest_circumference ON products(((attributes->>'chest_circumference')::numeric));
+CREATE INDEX idx_products_waist_circumference ON products(((attributes->>'waist_circumference')::numeric));
+CREATE INDEX idx_products_hip_circumference ON products(((attributes->>'hip_circumference')::numeric));